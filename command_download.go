@@ -1,27 +1,49 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/mkideal/cli"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
 )
 
 // download command
 type downloadT struct {
 	cli.Helper
-	Input       string `cli:"*i,input" usage:"input CSV file --input='/path/to/dir/input.csv'"`
-	ColumnName  string `cli:"*n,name" usage:"column name for filename --name='name'"`
-	ColumnLabel string `cli:"*l,label" usage:"column name for label --label='group'"`
-	ColumnURL   string `cli:"*u,url" usage:"column name for URL --url='path'"`
-	Parallel    int    `cli:"m,parallel" usage:"parallel number (multiple download) --parallel=2" dft:"2"`
-	OutputDir   string `cli:"o,output" usage:"outout dir --output='/path/to/dir/'"`
+	Input          string    `cli:"*i,input" usage:"input CSV file --input='/path/to/dir/input.csv'"`
+	InputFormat    string    `cli:"input-format" usage:"input file format, detected from the extension by default --input-format='[csv,tsv,jsonl,parquet]'"`
+	ColumnName     string    `cli:"n,name" usage:"column name for filename (required without --name-template) --name='name'"`
+	ColumnLabel    string    `cli:"*l,label" usage:"column name for label --label='group'"`
+	ColumnURL      string    `cli:"*u,url" usage:"column name for URL --url='path'"`
+	NameTemplate   string    `cli:"name-template" usage:"template of filename with column names, {hash8} of URL and {ext} --name-template='{id}_{hash8}{ext}'"`
+	LowerExt       bool      `cli:"lower-ext" usage:"use lower case file extension (e.g. '.JPG' => '.jpg')"`
+	Collision      string    `cli:"collision" usage:"how to handle filename collision between rows, 'reject' or 'rename' (add hash of URL) --collision=reject" dft:"reject"`
+	Parallel       int       `cli:"m,parallel" usage:"parallel number (multiple download) --parallel=2" dft:"2"`
+	OutputDir      string    `cli:"o,output" usage:"outout dir --output='/path/to/dir/'"`
+	ContentType    string    `cli:"content-type" usage:"comma separate allowed content types, checks Content-Type header and file contents --content-type='image/*,video/mp4'"`
+	RejectedOut    string    `cli:"rejected-out" usage:"output CSV file of rejected rows with the reason --rejected-out='./rejected.csv'"`
+	LabelDelimiter string    `cli:"label-delimiter" usage:"delimiter of multiple labels in --label column --label-delimiter='|'"`
+	MultiLabel     string    `cli:"multi-label" usage:"how to save multi-label files, 'link' (hardlink into each label dir), 'copy' or 'csv' (save into --output dir and write --label-out) --multi-label=link" dft:"link"`
+	LabelOut       string    `cli:"label-out" usage:"output CSV file of multi-labels for 'list --label-file' (default: <output>/labels.csv on --multi-label=csv) --label-out='./labels.csv'"`
+	Dedupe         bool      `cli:"dedupe" usage:"find duplicate files by content hash"`
+	DedupeMode     string    `cli:"dedupe-mode" usage:"how to save duplicate files, 'skip' (delete it) or 'symlink' (replace it with symlink to the original) --dedupe-mode=skip" dft:"skip"`
+	DedupeIndex    string    `cli:"dedupe-index" usage:"CSV file to persist content hash index across runs --dedupe-index='./dedupe_index.csv'"`
+	DedupeOut      string    `cli:"dedupe-out" usage:"output CSV file of duplicate rows with the original file --dedupe-out='./duplicates.csv'"`
+	HostParallel   int       `cli:"host-parallel" usage:"max parallel number per host, 0 means no limit --host-parallel=1" dft:"0"`
+	Rate           rateLimit `cli:"rate" usage:"max request rate per host, 0 means no limit --rate='10/s'" dft:"0"`
+	RateBurst      int       `cli:"rate-burst" usage:"burst size of request rate per host --rate-burst=1" dft:"1"`
+	HTTPOption
+	RetryOption
 }
 
 var downloader = &cli.Command{
@@ -34,43 +56,102 @@ var downloader = &cli.Command{
 func execDownload(ctx *cli.Context) error {
 	argv := ctx.Argv().(*downloadT)
 
-	r := newDownloadRunner(*argv)
+	r, err := newDownloadRunner(*argv)
+	if err != nil {
+		return err
+	}
 	return r.Run()
 }
 
 type DownloadRunner struct {
 	// parameters
-	Input       string
-	ColumnName  string
-	ColumnLabel string
-	ColumnURL   string
-	Parallel    int
-	OutputDir   string
+	Input          string
+	InputFormat    string
+	ColumnName     string
+	ColumnLabel    string
+	ColumnURL      string
+	NameTemplate   string
+	LowerExt       bool
+	Collision      string
+	Parallel       int
+	OutputDir      string
+	ContentType    string
+	RejectedOut    string
+	LabelDelimiter string
+	MultiLabel     string
+	LabelOut       string
+	Dedupe         bool
+	DedupeMode     string
+	DedupeIndex    string
+	DedupeOut      string
+	Retry          retryPolicy
+
+	client      *httpClient
+	hostLimiter *hostLimiter
 }
 
-func newDownloadRunner(p downloadT) DownloadRunner {
-	return DownloadRunner{
-		Input:       p.Input,
-		ColumnName:  p.ColumnName,
-		ColumnLabel: p.ColumnLabel,
-		ColumnURL:   p.ColumnURL,
-		Parallel:    p.Parallel,
-		OutputDir:   p.OutputDir,
+func newDownloadRunner(p downloadT) (DownloadRunner, error) {
+	client, err := newHTTPClient(p.HTTPOption)
+	if err != nil {
+		return DownloadRunner{}, err
 	}
+
+	return DownloadRunner{
+		Input:          p.Input,
+		InputFormat:    p.InputFormat,
+		ColumnName:     p.ColumnName,
+		ColumnLabel:    p.ColumnLabel,
+		ColumnURL:      p.ColumnURL,
+		NameTemplate:   p.NameTemplate,
+		LowerExt:       p.LowerExt,
+		Collision:      p.Collision,
+		Parallel:       p.Parallel,
+		OutputDir:      p.OutputDir,
+		ContentType:    p.ContentType,
+		RejectedOut:    p.RejectedOut,
+		LabelDelimiter: p.LabelDelimiter,
+		MultiLabel:     p.MultiLabel,
+		LabelOut:       p.LabelOut,
+		Dedupe:         p.Dedupe,
+		DedupeMode:     p.DedupeMode,
+		DedupeIndex:    p.DedupeIndex,
+		DedupeOut:      p.DedupeOut,
+		Retry:          newRetryPolicy(p.RetryOption),
+		client:         client,
+		hostLimiter:    newHostLimiter(p.HostParallel, p.Rate, p.RateBurst),
+	}, nil
 }
 
 func (r *DownloadRunner) Run() error {
+	if err := validateMultiLabelMode(r.MultiLabel); err != nil {
+		return err
+	}
+	if err := validateDedupeMode(r.DedupeMode); err != nil {
+		return err
+	}
+	if err := validateCollisionMode(r.Collision); err != nil {
+		return err
+	}
+
+	template := r.NameTemplate
+	switch {
+	case template == "" && r.ColumnName == "":
+		return fmt.Errorf("--name or --name-template is required")
+	case template == "":
+		template = "{" + r.ColumnName + "}{" + placeholderExt + "}"
+	}
+	nameTemplate := newFileNameTemplate(template, r.LowerExt)
 	maxReq := make(chan struct{}, r.Parallel)
 
-	f, err := NewCSVHandler(r.Input)
+	f, err := NewCSVHandlerWithFormat(r.Input, r.InputFormat)
 	if err != nil {
 		return err
 	}
+	defer f.Close() //nolint:errcheck
 
-	colName := r.ColumnName
 	colLabel := r.ColumnLabel
 	colURL := r.ColumnURL
-	err = f.checkHeaders(colName, colLabel, colURL)
+	err = f.checkHeaders(append([]string{colLabel, colURL}, nameTemplate.columns()...)...)
 	if err != nil {
 		return err
 	}
@@ -85,9 +166,51 @@ func (r *DownloadRunner) Run() error {
 	}
 
 	dirMap := newDirectoryMap()
+	names := newFileNameMap()
+	providers := newProviderMap()
+	typeFilter := newContentTypeFilter(strings.Split(r.ContentType, ","))
+	rejected := newRowReport(f.header, "reason")
+	duplicates := newRowReport(f.header, "duplicate_of")
+
+	// multi-label files are saved in --output dir on csv mode.
+	isCSVMode := r.LabelDelimiter != "" && r.MultiLabel == multiLabelCSV
+	labelOut := r.LabelOut
+	if labelOut == "" && isCSVMode {
+		labelOut = filepath.Join(outputDir, "labels.csv")
+	}
+	labelFile := newLabelFile(r.LabelDelimiter)
+	var index *hashIndex
+	if r.Dedupe {
+		index, err = loadHashIndex(outputDir, r.DedupeIndex)
+		if err != nil {
+			return err
+		}
+	}
+
+	// fetch gives the global slot back while waiting for the rate of the host and backoff of retry,
+	// not to starve requests to the other hosts.
+	fetch := func(url, host, filePath string, slot *requestSlot) (contentType string, err error) {
+		f, err := providers.newFetcher(r.client, url)
+		if err != nil {
+			return "", err
+		}
+		err = r.Retry.Do(func() (err error) {
+			if d := r.hostLimiter.reserve(host); d > 0 {
+				slot.release()
+				time.Sleep(d)
+			}
+			slot.acquire()
+			contentType, err = f.fetch(filePath, typeFilter)
+			if err != nil {
+				slot.release()
+			}
+			return err
+		}, f.isRetryableError)
+		return contentType, err
+	}
 
 	var wg sync.WaitGroup
-	var counter uint64
+	var num uint64
 	for {
 		line, err := f.Read()
 		if err != nil {
@@ -96,73 +219,261 @@ func (r *DownloadRunner) Run() error {
 		if len(line) == 0 {
 			break
 		}
+		num++
+
+		// labels and file paths are decided in order of the rows before download,
+		// so that the same row wins the file name collision on every run.
+		url := line[colURL]
+		labels, err := sanitizeLabels(splitLabels(line[colLabel], r.LabelDelimiter))
+		if err != nil {
+			fmt.Printf("[ERRORL:label] #=[%d], label=[%s], err=[%s]\n", num, line[colLabel], err)
+			rejected.add(line, err.Error())
+			continue
+		}
+		dirLabels := labels
+		if isCSVMode {
+			dirLabels = []string{""}
+		}
+
+		name, err := nameTemplate.execute(line, url)
+		if err != nil {
+			fmt.Printf("[ERRORL:name] #=[%d], url=[%s], err=[%s]\n", num, url, err)
+			rejected.add(line, err.Error())
+			continue
+		}
+
+		// the name has extension placeholder when the URL does not have extension,
+		// and it is replaced with the extension of the content type after download.
+		// The path with the placeholder is claimed, so that it collides with any extension.
+		dir := filepath.Join(outputDir, filepath.FromSlash(dirLabels[0]))
+		claimPath := filepath.Clean(filepath.Join(dir, name))
+		owner, ok := names.claim(claimPath, url)
+		if !ok && r.Collision == collisionRename {
+			name = addHashSuffix(name, url)
+			claimPath = filepath.Clean(filepath.Join(dir, name))
+			owner, ok = names.claim(claimPath, url)
+		}
+		if !ok {
+			fmt.Printf("[ERRORL:collision] #=[%d], filepath=[%s], url=[%s], owner=[%s]\n", num, claimPath, url, owner)
+			rejected.add(line, fmt.Sprintf("filename collision with [%s]", owner))
+			continue
+		}
+		filePath := filepath.Clean(filepath.Join(dir, withExt(name, "")))
+
+		// the other label dirs are skipped on collision.
+		copyDirs := make([]string, 0, len(dirLabels)-1)
+		for _, label := range dirLabels[1:] {
+			copyDir := filepath.Join(outputDir, filepath.FromSlash(label))
+			dst := filepath.Clean(filepath.Join(copyDir, name))
+			if owner, ok := names.claim(dst, url); !ok {
+				fmt.Printf("[ERRORL:collision] #=[%d], filepath=[%s], url=[%s], owner=[%s]\n", num, dst, url, owner)
+				continue
+			}
+			copyDirs = append(copyDirs, copyDir)
+		}
 
 		wg.Add(1)
-		go func(line map[string]string) {
-			maxReq <- struct{}{}
+		go func(num uint64, line map[string]string, url string, labels []string, name, dir, filePath string, copyDirs []string) {
+			// wait for the slot of the host first, not to occupy global slots.
+			host := getHost(url)
+			release := r.hostLimiter.acquire(host)
+			slot := newRequestSlot(maxReq)
+			slot.acquire()
 			defer func() {
-				<-maxReq
+				slot.release()
+				release()
 				wg.Done()
 			}()
 
-			num := atomic.AddUint64(&counter, 1)
 			fmt.Printf("exec #: [%d]\n", num)
 
-			url := line[colURL]
-			dir := filepath.Join(outputDir, line[colLabel])
 			err := dirMap.Create(dir)
 			if err != nil {
 				fmt.Printf("[ERRORL:mkdir] #=[%d], dir=[%s], err=[%s]\n", num, dir, err)
 				return
 			}
-
-			name := getFileName(line[colName], url)
-			filePath := filepath.Clean(filepath.Join(dir, name))
-			if isFileExist(filePath) {
-				fmt.Printf("[SKIP] already exists #=[%d], filepath=[%s]\n", num, filePath)
-				return
+			// skip the URL of the duplicate file on the previous run without download.
+			if r.Dedupe && r.DedupeMode == dedupeSkip {
+				if original, ok := index.findURL(url); ok {
+					fmt.Printf("[DUPLICATE] #=[%d], url=[%s], original=[%s]\n", num, url, original)
+					duplicates.add(line, original)
+					return
+				}
 			}
 
-			resp, err := http.Get(url) //nolint:gosec
-			if err != nil {
-				fmt.Printf("[ERRORL:http] #=[%d], url=[%s], err=[%s]\n", num, url, err)
-				return
+			existingName, isExist := findFileWithExt(dir, name)
+			if isExist {
+				name = existingName
+				filePath = filepath.Clean(filepath.Join(dir, name))
+				fmt.Printf("[SKIP] already exists #=[%d], filepath=[%s]\n", num, filePath)
+			} else {
+				contentType, err := fetch(url, host, filePath, slot)
+				if err != nil {
+					fmt.Printf("[ERRORL:download] #=[%d], url=[%s], err=[%s]\n", num, url, err)
+					rejected.add(line, err.Error())
+					return
+				}
+				if hasExtPlaceholder(name) {
+					name = withExt(name, getExtByContentType(contentType))
+					dst := filepath.Clean(filepath.Join(dir, name))
+					if err := renameNoReplace(filePath, dst); err != nil {
+						fmt.Printf("[ERRORL:rename] #=[%d], filepath=[%s], err=[%s]\n", num, dst, err)
+						os.Remove(filePath) //nolint:errcheck
+						rejected.add(line, err.Error())
+						return
+					}
+					filePath = dst
+				}
 			}
-			defer resp.Body.Close() //nolint:errcheck
 
-			body, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				fmt.Printf("[ERROR:ioutil] #=[%d], url=[%s], err=[%s]\n", num, url, err)
-				return
+			if r.Dedupe {
+				original, err := r.dedupe(index, outputDir, url, filePath, isExist)
+				switch {
+				case err != nil:
+					fmt.Printf("[ERRORL:dedupe] #=[%d], filepath=[%s], err=[%s]\n", num, filePath, err)
+				case original != "":
+					fmt.Printf("[DUPLICATE] #=[%d], filepath=[%s], original=[%s]\n", num, filePath, original)
+					duplicates.add(line, original)
+					if r.DedupeMode == dedupeSkip {
+						return
+					}
+				}
 			}
 
-			fp, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0600)
-			if err != nil {
-				fmt.Printf("[ERROR:OpenFile] #=[%d], filepath=[%s], err=[%s]\n", num, filePath, err)
-				return
+			// linked or copied files are recorded with the original path, not to be listed twice.
+			rel, relErr := filepath.Rel(outputDir, filePath)
+			if relErr == nil {
+				labelFile.add(rel, labels)
 			}
-			defer fp.Close() //nolint
 
-			_, err = fp.Write(body)
-			if err != nil {
-				fmt.Printf("[ERROR:WriteFile] #=[%d], filepath=[%s], err=[%s]\n", num, filePath, err)
+			// place the file into the other label dirs.
+			for _, copyDir := range copyDirs {
+				if err := dirMap.Create(copyDir); err != nil {
+					fmt.Printf("[ERRORL:mkdir] #=[%d], dir=[%s], err=[%s]\n", num, copyDir, err)
+					continue
+				}
+				dst := filepath.Clean(filepath.Join(copyDir, name))
+				if !isFileExist(dst) {
+					if err := linkOrCopyFile(filePath, dst, r.MultiLabel); err != nil {
+						fmt.Printf("[ERRORL:%s] #=[%d], filepath=[%s], err=[%s]\n", r.MultiLabel, num, dst, err)
+						continue
+					}
+				}
+				if dstRel, err := filepath.Rel(outputDir, dst); err == nil && relErr == nil {
+					labelFile.addCopy(dstRel, rel, labels)
+				}
 			}
-		}(line)
+		}(num, line, url, labels, name, dir, filePath, copyDirs)
 	}
 
 	wg.Wait()
+	if r.Dedupe && r.DedupeIndex != "" {
+		if err := index.WriteFile(r.DedupeIndex); err != nil {
+			return err
+		}
+	}
+	if r.DedupeOut != "" {
+		if err := duplicates.WriteFile(r.DedupeOut); err != nil {
+			return err
+		}
+	}
+	if labelOut != "" {
+		if err := labelFile.WriteFile(labelOut); err != nil {
+			return err
+		}
+	}
+	if r.RejectedOut != "" {
+		return rejected.WriteFile(r.RejectedOut)
+	}
 	return nil
 }
 
-// get file name with extension.
-func getFileName(name, uri string) string {
-	u, err := url.Parse(uri)
+var errEmptyBody = errors.New("empty body")
+
+// dedupe adds the file into the content hash index, and returns the path of the original file for duplicate.
+// The duplicate file is deleted or replaced with symlink by --dedupe-mode, but existing files are kept as is.
+func (r *DownloadRunner) dedupe(index *hashIndex, outputDir, url, filePath string, isExist bool) (original string, err error) {
+	// symlink from the previous run.
+	if info, err := os.Lstat(filePath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", nil
+	}
+
+	sum, err := getFileChecksum(filePath)
+	if err != nil {
+		return "", err
+	}
+	hash := sum.MD5
+	rel, err := filepath.Rel(outputDir, filePath)
 	if err != nil {
-		return name
+		return "", err
 	}
+	original, isDuplicate := index.add(hash, filepath.ToSlash(rel))
+	if !isDuplicate || isExist {
+		return original, nil
+	}
+	index.addURL(url, hash)
+
+	switch r.DedupeMode {
+	case dedupeSymlink:
+		err = replaceWithSymlink(filePath, filepath.Join(outputDir, filepath.FromSlash(original)))
+	default:
+		err = os.Remove(filePath)
+	}
+	return original, err
+}
+
+// httpStatusError is an error of HTTP status code.
+type httpStatusError struct {
+	StatusCode int
+}
 
-	ext := filepath.Ext(u.Path)
-	return name + ext
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("status code: [%d]", e.StatusCode)
+}
+
+// downloadURL streams the response body into the temporary file in the same dir,
+// and renames it to filePath after the whole body is written.
+func downloadURL(client *httpClient, url, filePath string, typeFilter contentTypeFilter) (contentType string, err error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	// reject error pages. (e.g. 404 HTML, 403 XML)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", httpStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body := bufio.NewReaderSize(resp.Body, sniffLen)
+	head, err := body.Peek(sniffLen)
+	switch {
+	case err == io.EOF && len(head) == 0:
+		return "", errEmptyBody
+	case err != nil && err != io.EOF:
+		return "", err
+	}
+	header := resp.Header.Get("Content-Type")
+	if err := typeFilter.validate(header, head); err != nil {
+		return "", err
+	}
+	return detectContentType(header, head), provider.WriteFile(filePath, body)
+}
+
+func isRetryableHTTPError(err error) bool {
+	if e, ok := err.(httpStatusError); ok {
+		return provider.IsRetryableStatus(e.StatusCode)
+	}
+	return provider.IsNetworkError(err)
+}
+
+// getHost returns host name of the URL, or empty string for invalid URL.
+func getHost(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Host
 }
 
 // to create new dir for the label.
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestFileServer returns the server responding the body with the content type of the path.
+func newTestFileServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cat.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write(testJPEGHead)
+		case "/dog.png":
+			w.Header().Set("Content-Type", "image/png; charset=binary")
+			_, _ = w.Write(testPNGHead)
+		case "/img":
+			// image without extension in the URL.
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write(append(append([]byte{}, testJPEGHead...), r.URL.RawQuery...))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(testHTMLHead)
+		case "/empty.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+		case "/forbidden.jpg":
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+		case "/unavailable.jpg":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			// error page with image content type.
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write(testJPEGHead)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestDownloadURL(t *testing.T) {
+	ts := newTestFileServer(t)
+	client, err := newHTTPClient(HTTPOption{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		list        []string
+		contentType string
+		statusCode  int
+		isValid     bool
+	}{
+		{"/cat.jpg", nil, "image/jpeg", 0, true},
+		{"/cat.jpg", []string{"image/jpeg"}, "image/jpeg", 0, true},
+		{"/dog.png", []string{"image/*"}, "image/png", 0, true},
+		{"/page.html", nil, "text/html", 0, true},
+		{"/page.html", []string{"image/*"}, "", 0, false},
+		{"/empty.jpg", nil, "", 0, false},
+		{"/forbidden.jpg", nil, "", http.StatusForbidden, false},
+		{"/unavailable.jpg", nil, "", http.StatusServiceUnavailable, false},
+		{"/not-found.jpg", nil, "", http.StatusNotFound, false},
+		{"/not-found.jpg", []string{"image/*"}, "", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"_"+strings.Join(tt.list, ","), func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "file")
+			contentType, err := downloadURL(client, ts.URL+tt.path, filePath, newContentTypeFilter(tt.list))
+			if !tt.isValid {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				var statusErr httpStatusError
+				switch {
+				case tt.statusCode == 0 && errors.As(err, &statusErr):
+					t.Errorf("unexpected status error: %v", err)
+				case tt.statusCode != 0 && (!errors.As(err, &statusErr) || statusErr.StatusCode != tt.statusCode):
+					t.Errorf("expected status [%d], but got [%v]", tt.statusCode, err)
+				}
+				if isFileExist(filePath) {
+					t.Errorf("expected no file for error: [%s]", filePath)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("expected [%s], but got [%s]", tt.contentType, contentType)
+			}
+			if !isFileExist(filePath) {
+				t.Errorf("expected file: [%s]", filePath)
+			}
+		})
+	}
+}
+
+func TestDownloadRunnerRejected(t *testing.T) {
+	ts := newTestFileServer(t)
+	input := writeTestFile(t, "input.csv", strings.Join([]string{
+		"id,label,url",
+		"1,cat," + ts.URL + "/cat.jpg",
+		"2,dog," + ts.URL + "/dog.png",
+		"3,cat," + ts.URL + "/page.html",
+		"4,cat," + ts.URL + "/forbidden.jpg",
+		"5,dog," + ts.URL + "/not-found.jpg",
+	}, "\n"))
+	outputDir := t.TempDir()
+	rejectedOut := filepath.Join(t.TempDir(), "rejected.csv")
+
+	r, err := newDownloadRunner(downloadT{
+		Input:       input,
+		ColumnName:  "id",
+		ColumnLabel: "label",
+		ColumnURL:   "url",
+		Collision:   collisionReject,
+		Parallel:    2,
+		OutputDir:   outputDir,
+		ContentType: "image/*",
+		RejectedOut: rejectedOut,
+		MultiLabel:  multiLabelLink,
+		DedupeMode:  dedupeSkip,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, file := range []string{"cat/1.jpg", "dog/2.png"} {
+		if !isFileExist(filepath.Join(outputDir, filepath.FromSlash(file))) {
+			t.Errorf("expected file: [%s]", file)
+		}
+	}
+	for _, label := range []string{"cat", "dog"} {
+		for _, name := range listTestDir(t, filepath.Join(outputDir, label)) {
+			if name != "1.jpg" && name != "2.png" {
+				t.Errorf("unexpected file: [%s/%s]", label, name)
+			}
+		}
+	}
+
+	rows, err := os.ReadFile(rejectedOut)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(rows)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 3 rejected rows with header, but got [%s]", rows)
+	}
+	if lines[0] != "id,label,url,reason" {
+		t.Errorf("unexpected header: [%s]", lines[0])
+	}
+	reasons := make(map[string]string)
+	for _, line := range lines[1:] {
+		cols := strings.Split(line, ",")
+		reasons[cols[0]] = cols[len(cols)-1]
+	}
+	expected := map[string]string{
+		"3": "content type is not allowed: header=[text/html]",
+		"4": "status code: [403]",
+		"5": "status code: [404]",
+	}
+	for id, reason := range expected {
+		if reasons[id] != reason {
+			t.Errorf("expected [%s], but got [%s] for #%s", reason, reasons[id], id)
+		}
+	}
+}
+
+func TestDownloadRunnerSanitizedLabels(t *testing.T) {
+	ts := newTestFileServer(t)
+	input := writeTestFile(t, "input.csv", strings.Join([]string{
+		"id,label,url",
+		`1,cat|cat/,` + ts.URL + "/cat.jpg",
+		`2,cat?|indoor|cat*,` + ts.URL + "/dog.png",
+	}, "\n"))
+	outputDir := t.TempDir()
+	labelOut := filepath.Join(t.TempDir(), "labels.csv")
+
+	r, err := newDownloadRunner(downloadT{
+		Input:          input,
+		ColumnName:     "id",
+		ColumnLabel:    "label",
+		ColumnURL:      "url",
+		Collision:      collisionReject,
+		Parallel:       1,
+		OutputDir:      outputDir,
+		LabelDelimiter: "|",
+		MultiLabel:     multiLabelLink,
+		LabelOut:       labelOut,
+		DedupeMode:     dedupeSkip,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, file := range []string{"cat/1.jpg", "cat_/2.png", "indoor/2.png"} {
+		if !isFileExist(filepath.Join(outputDir, filepath.FromSlash(file))) {
+			t.Errorf("expected file: [%s]", file)
+		}
+	}
+
+	labels, err := loadLabelFile(labelOut, "|")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lr := &ListRunner{labels: labels}
+	entries := lr.filterCopies([]listEntry{
+		{relPath: "cat/1.jpg"},
+		{relPath: "cat_/2.png"},
+		{relPath: "indoor/2.png"},
+	})
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.relPath)
+	}
+	if expected := []string{"cat/1.jpg", "cat_/2.png"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, but got %v", expected, paths)
+	}
+	if v, _ := labels.get("cat/1.jpg"); !reflect.DeepEqual(v, []string{"cat"}) {
+		t.Errorf("expected [cat], but got %v", v)
+	}
+	if v, _ := labels.get("cat_/2.png"); !reflect.DeepEqual(v, []string{"cat_", "indoor"}) {
+		t.Errorf("expected [cat_ indoor], but got %v", v)
+	}
+}
+
+func TestDownloadRunnerExtCollision(t *testing.T) {
+	ts := newTestFileServer(t)
+	input := writeTestFile(t, "input.csv", strings.Join([]string{
+		"id,label,url",
+		"1,cat," + ts.URL + "/cat.jpg",
+		"1,cat," + ts.URL + "/img?id=4",
+		"2,cat," + ts.URL + "/img?id=5",
+		"2,cat," + ts.URL + "/img?id=6",
+	}, "\n"))
+
+	tests := []struct {
+		collision string
+		files     []string
+		rejected  int
+	}{
+		{collisionReject, []string{"1.jpg", "2.jpg"}, 2},
+		{collisionRename, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.collision, func(t *testing.T) {
+			outputDir := t.TempDir()
+			rejectedOut := filepath.Join(t.TempDir(), "rejected.csv")
+			r, err := newDownloadRunner(downloadT{
+				Input:       input,
+				ColumnName:  "id",
+				ColumnLabel: "label",
+				ColumnURL:   "url",
+				Collision:   tt.collision,
+				Parallel:    4,
+				OutputDir:   outputDir,
+				RejectedOut: rejectedOut,
+				MultiLabel:  multiLabelLink,
+				DedupeMode:  dedupeSkip,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := r.Run(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			files := listTestDir(t, filepath.Join(outputDir, "cat"))
+			rows := strings.Split(strings.TrimSpace(readTestFile(t, rejectedOut)), "\n")
+			if len(rows)-1 != tt.rejected {
+				t.Errorf("expected [%d] rejected rows, but got %v", tt.rejected, rows[1:])
+			}
+			if tt.collision == collisionRename {
+				if len(files) != 4 {
+					t.Errorf("expected 4 files, but got %v", files)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Fatalf("expected %v, but got %v", tt.files, files)
+			}
+			// the first row of the name keeps the file.
+			if v := readTestFile(t, filepath.Join(outputDir, "cat", "1.jpg")); v != string(testJPEGHead) {
+				t.Errorf("expected the file of the first row, but got [%q]", v)
+			}
+			if v := readTestFile(t, filepath.Join(outputDir, "cat", "2.jpg")); v != string(testJPEGHead)+"id=5" {
+				t.Errorf("expected the file of the first row, but got [%q]", v)
+			}
+		})
+	}
+}
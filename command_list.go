@@ -9,17 +9,28 @@ import (
 	"strings"
 
 	"github.com/mkideal/cli"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
 )
 
 // list command
 type listT struct {
 	cli.Helper
-	Input          string `cli:"*i,input" usage:"image dir path --input='/path/to/image_dir'"`
+	Input          string `cli:"i,input" usage:"image dir path --input='/path/to/image_dir'"`
 	Output         string `cli:"*o,output" usage:"output CSV file path --output='./output.csv'" dft:"./output.csv"`
 	IncludeAllType bool   `cli:"a,all" usage:"use all files"`
 	Type           string `cli:"t,type" usage:"comma separate file extensions --type='jpg,jpeg,png,gif'" dft:"jpg,jpeg,png,gif"`
-	Format         string `cli:"f,format" usage:"set output format --format='[csv,sagemaker]'" dft:"csv"`
+	Format         string `cli:"f,format" usage:"set output format --format='[csv,sagemaker,automl-classification,vertex-classification]'" dft:"csv"`
 	PathPrefix     string `cli:"*p,prefix" usage:"prefix for file path --prefix='gs://<your-bucket-name>'" dft:""`
+	CloudProvider  string `cli:"c,provider" usage:"cloud provider name to list files from the bucket instead of --input --provider='[s3,gcs,azblob,local]'"`
+	Bucket         string `cli:"b,bucket" usage:"bucket name of S3/GCS (container name of Azure, root dir of local) --bucket='<your-bucket-name>'"`
+	ObjectPrefix   string `cli:"object-prefix" usage:"prefix for S3/GCS to list files (default: path of --prefix) --object-prefix='foo/bar'"`
+	LabelFile      string `cli:"label-file" usage:"multi-label CSV file from 'download --label-out' to use labels instead of dir name --label-file='./labels.csv'"`
+	LabelDelimiter string `cli:"label-delimiter" usage:"delimiter of labels in --label-file --label-delimiter='|'" dft:"|"`
+	LabelAttribute string `cli:"label-attribute" usage:"label attribute name for sagemaker format --label-attribute='class'" dft:"class"`
+	JobName        string `cli:"job-name" usage:"job name for sagemaker format (default: labeling-job/<label-attribute>) --job-name='labeling-job/my-job'"`
+	ClassMap       string `cli:"class-map" usage:"JSON file of label to class id for sagemaker format --class-map='./class_map.json'"`
+	SplitOption
 }
 
 var list = &cli.Command{
@@ -38,11 +49,28 @@ func execList(ctx *cli.Context) error {
 	argv := ctx.Argv().(*listT)
 
 	r := newListRunner(*argv)
-	formatter, err := createListFormat(argv.Format)
+	splitter, err := newDatasetSplitter(argv.SplitOption)
+	if err != nil {
+		return err
+	}
+	r.Splitter = splitter
+
+	opt := formatOption{
+		labelAttribute: argv.LabelAttribute,
+		jobName:        argv.JobName,
+	}
+	if argv.ClassMap != "" {
+		classMap, err := readClassMap(argv.ClassMap)
+		if err != nil {
+			return err
+		}
+		opt.classMap = classMap
+	}
+	writer, err := createListFormat(argv.Format, opt)
 	if err != nil {
 		return err
 	}
-	r.Formatter = formatter
+	r.Writer = writer
 	return r.Run()
 }
 
@@ -54,8 +82,25 @@ type ListRunner struct {
 	Type           string
 	Format         string
 	PathPrefix     string
+	CloudProvider  string
+	Bucket         string
+	ObjectPrefix   string
+	LabelFile      string
+	LabelDelimiter string
+	SplitFiles     bool
+
+	Writer   recordWriter
+	Splitter datasetSplitter
+	labels   *labelFile
+}
 
-	Formatter formatter
+// listEntry is a file in the list.
+type listEntry struct {
+	// path is URL path with the prefix.
+	path string
+	// relPath is relative path from the input dir (or the prefix of the bucket).
+	relPath string
+	label   string
 }
 
 func newListRunner(p listT) ListRunner {
@@ -66,12 +111,25 @@ func newListRunner(p listT) ListRunner {
 		Type:           p.Type,
 		Format:         p.Format,
 		PathPrefix:     p.PathPrefix,
+		CloudProvider:  p.CloudProvider,
+		Bucket:         p.Bucket,
+		ObjectPrefix:   p.ObjectPrefix,
+		LabelFile:      p.LabelFile,
+		LabelDelimiter: p.LabelDelimiter,
+		SplitFiles:     p.SplitFiles,
 	}
 }
 
 func (r *ListRunner) Run() error {
-	f, err := NewFileHandler(r.Output)
-	if err != nil {
+	switch {
+	case r.CloudProvider != "" && r.Bucket == "":
+		return fmt.Errorf("--bucket is required for --provider")
+	case r.CloudProvider == "" && r.Input == "":
+		return fmt.Errorf("--input or --provider is required")
+	}
+
+	// try to open before starting process
+	if _, err := NewFileHandler(r.Output); err != nil {
 		return err
 	}
 
@@ -79,23 +137,61 @@ func (r *ListRunner) Run() error {
 	if r.IncludeAllType {
 		types.setIncludeAll(r.IncludeAllType)
 	}
+	var err error
+	if r.LabelFile != "" {
+		r.labels, err = loadLabelFile(r.LabelFile, r.LabelDelimiter)
+		if err != nil {
+			return err
+		}
+	}
 
 	pathPrefix = r.PathPrefix
-	baseDir = fmt.Sprintf("%s/", filepath.Clean(r.Input))
-	result, err := r.GetFilesFromDir(baseDir, types)
+	var entries []listEntry
+	if r.CloudProvider != "" {
+		entries, err = r.GetFilesFromBucket(types)
+	} else {
+		baseDir = fmt.Sprintf("%s/", filepath.Clean(r.Input))
+		entries, err = r.GetFilesFromDir(baseDir, types)
+	}
 	if err != nil {
 		return err
 	}
-	return f.WriteAll(result)
+	entries = r.filterCopies(entries)
+
+	items := make([]splitItem, len(entries))
+	labels := make([][]string, len(entries))
+	for i, e := range entries {
+		label := e.label
+		if r.Splitter.isDirMethod() {
+			label = trimSplitDir(label)
+		}
+		labels[i] = r.getLabels(e.relPath, label)
+		items[i] = splitItem{
+			key:   e.relPath,
+			label: strings.Join(labels[i], ","),
+		}
+	}
+	splits := r.Splitter.assign(items)
+
+	records := make([]labelRecord, len(entries))
+	for i, e := range entries {
+		records[i] = labelRecord{
+			path:   e.path,
+			name:   e.relPath,
+			labels: labels[i],
+			split:  splits[i],
+		}
+	}
+	return writeRecords(r.Writer, r.Output, records, r.SplitFiles)
 }
 
-func (r *ListRunner) GetFilesFromDir(dir string, types fileType) ([]string, error) {
+func (r *ListRunner) GetFilesFromDir(dir string, types fileType) ([]listEntry, error) {
 	files, err := ioutil.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	paths := make([]string, 0, len(files))
+	entries := make([]listEntry, 0, len(files))
 	for _, file := range files {
 		fileName := file.Name()
 		if file.IsDir() {
@@ -103,7 +199,7 @@ func (r *ListRunner) GetFilesFromDir(dir string, types fileType) ([]string, erro
 			if err != nil {
 				return nil, err
 			}
-			paths = append(paths, sublist...)
+			entries = append(entries, sublist...)
 			continue
 		}
 
@@ -112,10 +208,87 @@ func (r *ListRunner) GetFilesFromDir(dir string, types fileType) ([]string, erro
 		}
 
 		label := strings.TrimPrefix(dir, baseDir)
-		path := getURLPath(pathPrefix, path.Join(label, fileName))
-		paths = append(paths, r.Formatter.format(path, label))
+		relPath := path.Join(label, fileName)
+		entries = append(entries, listEntry{
+			path:    getURLPath(pathPrefix, relPath),
+			relPath: relPath,
+			label:   label,
+		})
+	}
+	return entries, nil
+}
+
+// GetFilesFromBucket lists objects in the bucket and treats the dir after the prefix as label.
+func (r *ListRunner) GetFilesFromBucket(types fileType) ([]listEntry, error) {
+	// create Cloud Provider client from env vars
+	cli, err := provider.Create(r.CloudProvider)
+	if err != nil {
+		return nil, err
 	}
-	return paths, nil
+
+	objectPrefix := r.ObjectPrefix
+	if objectPrefix == "" {
+		u, err := url.Parse(pathPrefix)
+		if err != nil {
+			return nil, err
+		}
+		objectPrefix = u.Path
+	}
+
+	listPrefix := getListPrefix(objectPrefix)
+	objects, err := cli.List(provider.ListOption{
+		BucketName: r.Bucket,
+		Prefix:     listPrefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]listEntry, 0, len(objects))
+	for _, obj := range objects {
+		fileName := path.Base(obj.Path)
+		if !types.isTarget(fileName) {
+			continue
+		}
+
+		// use the object path for URL, because --object-prefix can be different from the path of --prefix.
+		label := getLabelFromObjectPath(obj.Path, listPrefix)
+		entries = append(entries, listEntry{
+			path:    getObjectURL(pathPrefix, obj.Path),
+			relPath: path.Join(label, fileName),
+			label:   label,
+		})
+	}
+	return entries, nil
+}
+
+// filterCopies removes the files linked or copied from the other file in --label-file,
+// because the original file has all of the labels.
+func (r *ListRunner) filterCopies(entries []listEntry) []listEntry {
+	if r.labels == nil {
+		return entries
+	}
+
+	result := entries[:0]
+	for _, e := range entries {
+		if !r.labels.isCopy(e.relPath) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// getLabels returns labels from --label-file, or dirLabel when the file is not in it.
+func (r *ListRunner) getLabels(relPath, dirLabel string) []string {
+	if r.labels != nil {
+		if labels, ok := r.labels.get(relPath); ok {
+			return labels
+		}
+	}
+	if dirLabel == "" {
+		return nil
+	}
+	return []string{dirLabel}
 }
 
 func getURLPath(prefix, filepath string) string {
@@ -123,3 +296,11 @@ func getURLPath(prefix, filepath string) string {
 	u.Path = path.Join(u.Path, filepath)
 	return u.String()
 }
+
+// getObjectURL returns URL of the object path with scheme and host of the prefix.
+// (e.g.) prefix='gs://bucket/foo', objectPath='bar/cat/1.jpg' => 'gs://bucket/bar/cat/1.jpg'
+func getObjectURL(prefix, objectPath string) string {
+	u, _ := url.Parse(prefix)
+	u.Path = "/" + strings.TrimPrefix(objectPath, "/")
+	return u.String()
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestGetObjectURL(t *testing.T) {
+	tests := []struct {
+		prefix     string
+		objectPath string
+		expected   string
+	}{
+		{"gs://bucket/foo", "foo/cat/1.jpg", "gs://bucket/foo/cat/1.jpg"},
+		{"gs://bucket/foo", "bar/cat/1.jpg", "gs://bucket/bar/cat/1.jpg"},
+		{"gs://bucket", "cat/1.jpg", "gs://bucket/cat/1.jpg"},
+		{"s3://bucket/", "/cat/1.jpg", "s3://bucket/cat/1.jpg"},
+	}
+
+	for _, tt := range tests {
+		result := getObjectURL(tt.prefix, tt.objectPath)
+		if result != tt.expected {
+			t.Errorf("prefix=[%s] objectPath=[%s]: expected [%s], but got [%s]", tt.prefix, tt.objectPath, tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mkideal/cli"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// pull command
+type pullT struct {
+	cli.Helper
+	CloudProvider  string `cli:"*c,provider" usage:"cloud provider name for the bucket --provider='[s3,gcs,azblob,local]'"`
+	Bucket         string `cli:"*b,bucket" usage:"bucket name of S3/GCS (container name of Azure, root dir of local) --bucket='<your-bucket-name>'"`
+	PathPrefix     string `cli:"*p,prefix" usage:"prefix for S3/GCS --prefix='foo/bar'"`
+	Type           string `cli:"t,type" usage:"comma separate file extensions --type='jpg,jpeg,png,gif'" dft:"jpg,jpeg,png,gif"`
+	IncludeAllType bool   `cli:"a,all" usage:"use all files"`
+	Parallel       int    `cli:"m,parallel" usage:"parallel number (multiple download) --parallel=2" dft:"2"`
+	OutputDir      string `cli:"o,output" usage:"output dir --output='/path/to/dir/'"`
+	RetryOption
+}
+
+var puller = &cli.Command{
+	Name: "pull",
+	Desc: "Download files from Cloud Bucket(S3, GCS, Azure Blob Storage) into --output dir",
+	Argv: func() interface{} { return new(pullT) },
+	Fn:   execPull,
+}
+
+func execPull(ctx *cli.Context) error {
+	argv := ctx.Argv().(*pullT)
+
+	r := newPullRunner(*argv)
+	return r.Run()
+}
+
+type PullRunner struct {
+	// parameters
+	CloudProvider  string
+	Bucket         string
+	PathPrefix     string
+	Type           string
+	IncludeAllType bool
+	Parallel       int
+	OutputDir      string
+	Retry          retryPolicy
+}
+
+func newPullRunner(p pullT) PullRunner {
+	return PullRunner{
+		CloudProvider:  p.CloudProvider,
+		Bucket:         p.Bucket,
+		PathPrefix:     p.PathPrefix,
+		Type:           p.Type,
+		IncludeAllType: p.IncludeAllType,
+		Parallel:       p.Parallel,
+		OutputDir:      p.OutputDir,
+		Retry:          newRetryPolicy(p.RetryOption),
+	}
+}
+
+func (r *PullRunner) Run() error {
+	// create Cloud Provider client from env vars
+	cli, err := provider.Create(r.CloudProvider)
+	if err != nil {
+		return err
+	}
+	if err := cli.CheckBucket(r.Bucket); err != nil {
+		return err
+	}
+
+	types := newFileType(strings.Split(r.Type, ","))
+	if r.IncludeAllType {
+		types.setIncludeAll(r.IncludeAllType)
+	}
+
+	outputDir := r.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	err = makeDir(outputDir)
+	if err != nil {
+		return err
+	}
+
+	listPrefix := getListPrefix(r.PathPrefix)
+	objects, err := cli.List(provider.ListOption{
+		BucketName: r.Bucket,
+		Prefix:     listPrefix,
+	})
+	if err != nil {
+		return err
+	}
+
+	maxReq := make(chan struct{}, r.Parallel)
+	dirMap := newDirectoryMap()
+
+	var wg sync.WaitGroup
+	var counter, failed uint64
+	for _, obj := range objects {
+		fileName := path.Base(obj.Path)
+		if !types.isTarget(fileName) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(objectPath, fileName string) {
+			maxReq <- struct{}{}
+			defer func() {
+				<-maxReq
+				wg.Done()
+			}()
+
+			num := atomic.AddUint64(&counter, 1)
+			fmt.Printf("exec #%d: [%s]\n", num, objectPath)
+
+			label := getLabelFromObjectPath(objectPath, listPrefix)
+			filePath, err := joinUnderDir(outputDir, path.Join(label, fileName))
+			if err != nil {
+				atomic.AddUint64(&failed, 1)
+				fmt.Printf("[ERROR:path] #=[%d], path=[%s], err=[%s]\n", num, objectPath, err)
+				return
+			}
+			dir := filepath.Dir(filePath)
+			err = dirMap.Create(dir)
+			if err != nil {
+				atomic.AddUint64(&failed, 1)
+				fmt.Printf("[ERROR:mkdir] #=[%d], dir=[%s], err=[%s]\n", num, dir, err)
+				return
+			}
+
+			if isFileExist(filePath) {
+				fmt.Printf("[SKIP] already exists #=[%d], filepath=[%s]\n", num, filePath)
+				return
+			}
+
+			err = r.Retry.Do(func() error {
+				return cli.Download(provider.FileOption{
+					SrcPath:    objectPath,
+					BucketName: r.Bucket,
+					DstPath:    filePath,
+				})
+			}, cli.IsRetryableError)
+			if err != nil {
+				atomic.AddUint64(&failed, 1)
+				fmt.Printf("[ERROR]: #=[%d] path=[%s] error=[%s]\n", num, objectPath, err.Error())
+			}
+		}(obj.Path, fileName)
+	}
+
+	wg.Wait()
+	if n := atomic.LoadUint64(&failed); n > 0 {
+		return fmt.Errorf("failed on %d files", n)
+	}
+	return nil
+}
+
+// joinUnderDir joins the slash separated path of the object to the dir,
+// and returns error when the path is outside of the dir. (e.g. '../../etc/passwd')
+func joinUnderDir(dir, objectPath string) (string, error) {
+	filePath := filepath.Join(dir, filepath.FromSlash(objectPath))
+	rel, err := filepath.Rel(dir, filePath)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path is outside of the dir: [%s]", objectPath)
+	}
+	return filePath, nil
+}
+
+// getListPrefix returns prefix for listing objects in the dir.
+// (e.g.) '/foo/bar' => 'foo/bar/'
+func getListPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// getLabelFromObjectPath returns label from the object path.
+// (e.g.) prefix='foo/bar/', objectPath='foo/bar/cat/1.jpg' => 'cat'
+func getLabelFromObjectPath(objectPath, prefix string) string {
+	dir := path.Dir(strings.TrimPrefix(objectPath, prefix))
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
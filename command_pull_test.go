@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestJoinUnderDir(t *testing.T) {
+	tests := []struct {
+		objectPath string
+		expected   string
+		isErr      bool
+	}{
+		{"cat/1.jpg", filepath.Join("out", "cat", "1.jpg"), false},
+		{"1.jpg", filepath.Join("out", "1.jpg"), false},
+		{"cat/../1.jpg", filepath.Join("out", "1.jpg"), false},
+		{"../1.jpg", "", true},
+		{"cat/../../1.jpg", "", true},
+		{"../../etc/passwd", "", true},
+		{"..", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.objectPath, func(t *testing.T) {
+			result, err := joinUnderDir("out", tt.objectPath)
+			if tt.isErr {
+				if err == nil {
+					t.Errorf("expected error, but got [%s]", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+}
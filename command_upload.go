@@ -12,7 +12,9 @@ import (
 	"github.com/mkideal/cli"
 
 	"github.com/evalphobia/cloud-label-uploader/provider"
+	_ "github.com/evalphobia/cloud-label-uploader/provider/azblob"
 	_ "github.com/evalphobia/cloud-label-uploader/provider/gcs"
+	_ "github.com/evalphobia/cloud-label-uploader/provider/local"
 	_ "github.com/evalphobia/cloud-label-uploader/provider/s3"
 )
 
@@ -23,15 +25,25 @@ type uploadT struct {
 	Type           string `cli:"t,type" usage:"comma separate file extensions --type='jpg,jpeg,png,gif'" dft:"jpg,jpeg,png,gif"`
 	IncludeAllType bool   `cli:"a,all" usage:"use all files"`
 	InputLabelFile string `cli:"l,label" usage:"label file for training (outputted CSV file) --label='/path/to/output.csv'"`
-	CloudProvider  string `cli:"*c,provider" usage:"cloud provider name for the bucket --provider='[s3,gcs]'"`
-	Bucket         string `cli:"*b,bucket" usage:"bucket name of S3/GCS --bucket='<your-bucket-name>'"`
+	CloudProvider  string `cli:"*c,provider" usage:"cloud provider name for the bucket --provider='[s3,gcs,azblob,local]'"`
+	Bucket         string `cli:"*b,bucket" usage:"bucket name of S3/GCS (container name of Azure, root dir of local) --bucket='<your-bucket-name>'"`
 	PathPrefix     string `cli:"*p,prefix" usage:"prefix for S3/GCS --prefix='foo/bar'"`
 	Parallel       int    `cli:"m,parallel" usage:"parallel number (multiple upload) --parallel=2" dft:"2"`
+	Manifest       string `cli:"manifest" usage:"manifest file to record uploaded files and skip unchanged files --manifest='./manifest.jsonl'"`
+	Resume         bool   `cli:"resume" usage:"skip files in --manifest without checking changes (for interrupted runs)"`
+	Verify         bool   `cli:"verify" usage:"verify checksum (MD5/CRC32C) of uploaded files"`
+	Sync           bool   `cli:"sync" usage:"delete objects under --prefix which do not exist in --input dir"`
+	DryRun         bool   `cli:"dry-run" usage:"show the plan without uploading or deleting files"`
+	CheckExists    bool   `cli:"check-exists" usage:"check existence of objects on --dry-run"`
+	PlanOutput     string `cli:"plan-out" usage:"output file of --dry-run plan (.csv, .json or .jsonl) --plan-out='./plan.csv'"`
+	FailedOutput   string `cli:"failed-out" usage:"output CSV file of failed files --failed-out='./failed.csv'"`
+	FailedInput    string `cli:"from-failed" usage:"upload files in the CSV of --failed-out instead of whole --input dir --from-failed='./failed.csv'"`
+	RetryOption
 }
 
 var uploader = &cli.Command{
 	Name: "upload",
-	Desc: "Upload files to Cloud Bucket(S3, GCS) from --input dir",
+	Desc: "Upload files to Cloud Bucket(S3, GCS, Azure Blob Storage) from --input dir",
 	Argv: func() interface{} { return new(uploadT) },
 	Fn:   execUpload,
 }
@@ -53,6 +65,16 @@ type UploadRunner struct {
 	Bucket         string
 	PathPrefix     string
 	Parallel       int
+	Manifest       string
+	Resume         bool
+	Verify         bool
+	Sync           bool
+	DryRun         bool
+	CheckExists    bool
+	PlanOutput     string
+	FailedOutput   string
+	FailedInput    string
+	Retry          retryPolicy
 
 	Formatter formatter
 }
@@ -67,17 +89,37 @@ func newUploadRunner(p uploadT) UploadRunner {
 		Bucket:         p.Bucket,
 		PathPrefix:     p.PathPrefix,
 		Parallel:       p.Parallel,
+		Manifest:       p.Manifest,
+		Resume:         p.Resume,
+		Verify:         p.Verify,
+		Sync:           p.Sync,
+		DryRun:         p.DryRun,
+		CheckExists:    p.CheckExists,
+		PlanOutput:     p.PlanOutput,
+		FailedOutput:   p.FailedOutput,
+		FailedInput:    p.FailedInput,
+		Retry:          newRetryPolicy(p.RetryOption),
 	}
 }
 
-func (r *UploadRunner) Run() error {
+func (r *UploadRunner) Run() (err error) {
+	switch {
+	case r.Resume && r.Manifest == "":
+		return fmt.Errorf("--manifest is required for --resume")
+	case r.Sync && r.FailedInput != "":
+		return fmt.Errorf("--sync cannot be used with --from-failed")
+	case r.Sync && getListPrefix(r.PathPrefix) == "":
+		// avoid deleting all objects in the bucket.
+		return fmt.Errorf("--sync cannot be used with empty --prefix")
+	}
+
 	// create Cloud Provider client from env vars
 	cli, err := provider.Create(r.CloudProvider)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if err := cli.CheckBucket(r.Bucket); err != nil {
-		panic(err)
+		return err
 	}
 
 	types := newFileType(strings.Split(r.Type, ","))
@@ -86,18 +128,73 @@ func (r *UploadRunner) Run() error {
 	}
 
 	u := Uploader{
-		Provider:   cli,
-		FileTypes:  types,
-		BaseDir:    fmt.Sprintf("%s/", filepath.Clean(r.Input)),
-		Bucket:     r.Bucket,
-		PathPrefix: strings.TrimLeft(r.PathPrefix, "/"),
-		maxReq:     make(chan struct{}, r.Parallel),
+		Provider:    cli,
+		FileTypes:   types,
+		BaseDir:     fmt.Sprintf("%s/", filepath.Clean(r.Input)),
+		Bucket:      r.Bucket,
+		PathPrefix:  strings.TrimLeft(r.PathPrefix, "/"),
+		Resume:      r.Resume,
+		Verify:      r.Verify,
+		DryRun:      r.DryRun,
+		CheckExists: r.CheckExists,
+		Retry:       r.Retry,
+		maxReq:      make(chan struct{}, r.Parallel),
+	}
+	if r.Manifest != "" {
+		openManifest := newUploadManifest
+		if r.DryRun {
+			openManifest = loadUploadManifest
+		}
+		m, err := openManifest(r.Manifest)
+		if err != nil {
+			return err
+		}
+		u.Manifest = m
+		defer func() {
+			if closeErr := m.Close(); err == nil {
+				err = closeErr
+			}
+		}()
 	}
 	if r.InputLabelFile != "" {
 		u.UploadFileFromPath(r.InputLabelFile)
 	}
-	u.UploadFilesFromDir(u.BaseDir)
+	var readErr error
+	if r.FailedInput != "" {
+		readErr = u.UploadFilesFromList(r.FailedInput)
+	} else {
+		readErr = u.UploadFilesFromDir(u.BaseDir)
+	}
+	// wait for the files already started, and write the report even if reading files is failed.
 	u.wg.Wait()
+
+	// delete objects only after the whole dir is read successfully.
+	if r.Sync && readErr == nil {
+		if err := u.DeleteOrphanObjects(); err != nil {
+			return err
+		}
+	}
+	if r.DryRun {
+		if r.PlanOutput != "" {
+			if err := u.plans.WriteFile(r.PlanOutput); err != nil {
+				return err
+			}
+		}
+		return readErr
+	}
+
+	fmt.Println(u.result.String())
+	if r.FailedOutput != "" {
+		if err := u.result.WriteFailures(r.FailedOutput); err != nil {
+			return err
+		}
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if n := u.result.failedCount(); n > 0 {
+		return fmt.Errorf("failed on %d files", n)
+	}
 	return nil
 }
 
@@ -107,22 +204,36 @@ type Uploader struct {
 	Bucket     string
 	PathPrefix string
 	BaseDir    string
+	Manifest   *uploadManifest
+	Resume     bool
+	Verify     bool
+	DryRun     bool
+	// CheckExists checks existence of objects on DryRun.
+	CheckExists bool
+	Retry       retryPolicy
 
 	wg      sync.WaitGroup
 	maxReq  chan struct{}
 	counter uint64
+
+	// object paths of the local files, used for sync.
+	localObjects map[string]struct{}
+	plans        uploadPlanList
+	result       uploadResult
 }
 
-func (u *Uploader) UploadFilesFromDir(dir string) {
+func (u *Uploader) UploadFilesFromDir(dir string) error {
 	files, err := ioutil.ReadDir(dir)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	for _, file := range files {
 		fileName := file.Name()
 		if file.IsDir() {
-			u.UploadFilesFromDir(filepath.Join(dir, fileName))
+			if err := u.UploadFilesFromDir(filepath.Join(dir, fileName)); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -130,39 +241,113 @@ func (u *Uploader) UploadFilesFromDir(dir string) {
 			continue
 		}
 
+		isDuplicate := u.addLocalObject(u.getObjectPath(dir, fileName))
 		u.wg.Add(1)
-		go func(dir, fileName string) {
-			u.maxReq <- struct{}{}
-			defer func() {
-				<-u.maxReq
-				u.wg.Done()
-			}()
+		go u.uploadAsync(dir, fileName, isDuplicate)
+	}
+	return nil
+}
 
-			num := atomic.AddUint64(&u.counter, 1)
-			fmt.Printf("exec #%d: [%s] [%s]\n", num, dir, fileName)
+// UploadFilesFromList uploads files in the CSV file outputted by --failed-out.
+func (u *Uploader) UploadFilesFromList(file string) error {
+	paths, err := readFailedFiles(file)
+	if err != nil {
+		return err
+	}
 
-			skip, err := u.upload(dir, fileName)
-			switch {
-			case err != nil:
-				fmt.Printf("[ERROR]: #=[%d] path=[%s] error=[%s]\n", num, filepath.Join(dir, fileName), err.Error())
-			case skip:
-				fmt.Printf("[SKIP] already exists #=[%d], filepath=[%s]\n", num, filepath.Join(dir, fileName))
-			}
-		}(dir, fileName)
+	for _, path := range paths {
+		dir, fileName := filepath.Dir(path), filepath.Base(path)
+		isDuplicate := u.addLocalObject(u.getObjectPath(dir, fileName))
+		u.wg.Add(1)
+		go u.uploadAsync(dir, fileName, isDuplicate)
 	}
+	return nil
 }
 
 func (u *Uploader) UploadFileFromPath(path string) {
-	_, err := u.upload(filepath.Dir(path), filepath.Base(path))
-	if err != nil {
-		panic(err)
+	dir, fileName := filepath.Dir(path), filepath.Base(path)
+	isDuplicate := u.addLocalObject(u.getObjectPath(dir, fileName))
+	u.wg.Add(1)
+	u.uploadAsync(dir, fileName, isDuplicate)
+}
+
+func (u *Uploader) uploadAsync(dir, fileName string, isDuplicate bool) {
+	u.maxReq <- struct{}{}
+	defer func() {
+		<-u.maxReq
+		u.wg.Done()
+	}()
+
+	if u.DryRun {
+		u.plans.add(u.plan(dir, fileName, isDuplicate))
+		return
+	}
+
+	num := atomic.AddUint64(&u.counter, 1)
+	fmt.Printf("exec #%d: [%s] [%s]\n", num, dir, fileName)
+
+	var skip bool
+	err := u.Retry.Do(func() (err error) {
+		skip, err = u.upload(dir, fileName)
+		return err
+	}, u.Provider.IsRetryableError)
+	switch {
+	case err != nil:
+		fmt.Printf("[ERROR]: #=[%d] path=[%s] error=[%s]\n", num, filepath.Join(dir, fileName), err.Error())
+		u.result.addFailure(filepath.Join(dir, fileName), u.getObjectPath(dir, fileName), err)
+	case skip:
+		fmt.Printf("[SKIP] already exists #=[%d], filepath=[%s]\n", num, filepath.Join(dir, fileName))
+		u.result.addSkipped()
+	default:
+		u.result.addUploaded()
 	}
 }
 
 func (u *Uploader) upload(dir, fileName string) (skip bool, err error) {
-	label := u.getLabel(dir)
-	objectPath := path.Join(u.PathPrefix, label, fileName)
+	objectPath := u.getObjectPath(dir, fileName)
+	srcPath := filepath.Join(dir, fileName)
+	if u.Manifest == nil {
+		return u.uploadIfNotExists(srcPath, objectPath)
+	}
 
+	entry, err := newManifestEntry(srcPath, u.Bucket, objectPath)
+	if err != nil {
+		return false, err
+	}
+
+	prev, ok := u.Manifest.get(entry.key())
+	if !ok {
+		// record the existing object too, to skip it without any network call on the next run.
+		skip, err := u.uploadIfNotExists(srcPath, objectPath)
+		if err != nil {
+			return false, err
+		}
+		return skip, u.addManifest(entry)
+	}
+	if u.Resume {
+		return true, nil
+	}
+
+	// skip unchanged file without any network call, and re-upload modified file.
+	isSame, err := entry.isSame(prev)
+	switch {
+	case err != nil:
+		return false, err
+	case isSame && entry.ModTime.Equal(prev.ModTime):
+		return true, nil
+	case isSame:
+		// update mtime to avoid calculating hash again.
+		return true, u.Manifest.add(entry)
+	}
+
+	err = u.uploadFile(srcPath, objectPath)
+	if err != nil {
+		return false, err
+	}
+	return false, u.addManifest(entry)
+}
+
+func (u *Uploader) uploadIfNotExists(srcPath, objectPath string) (skip bool, err error) {
 	// check file existence
 	ok, err := u.Provider.IsExists(provider.FileOption{
 		BucketName: u.Bucket,
@@ -176,11 +361,201 @@ func (u *Uploader) upload(dir, fileName string) (skip bool, err error) {
 	}
 
 	// upload file
-	return false, u.Provider.UploadFromLocalFile(provider.FileOption{
-		SrcPath:    filepath.Join(dir, fileName),
+	return false, u.uploadFile(srcPath, objectPath)
+}
+
+// uploadFile uploads the file, and uploads again when the checksum does not match.
+func (u *Uploader) uploadFile(srcPath, objectPath string) error {
+	opt := provider.FileOption{
+		SrcPath:    srcPath,
 		BucketName: u.Bucket,
 		DstPath:    objectPath,
+	}
+
+	const maxVerifyRetry = 1
+	for i := 0; ; i++ {
+		if err := u.Provider.UploadFromLocalFile(opt); err != nil {
+			return err
+		}
+		if !u.Verify {
+			return nil
+		}
+
+		err := u.verify(opt)
+		if err == nil || i >= maxVerifyRetry {
+			return err
+		}
+		fmt.Printf("[RETRY] path=[%s] error=[%s]\n", srcPath, err.Error())
+	}
+}
+
+// verify compares size and checksum between the local file and the uploaded object.
+func (u *Uploader) verify(opt provider.FileOption) error {
+	local, err := getFileChecksum(opt.SrcPath)
+	if err != nil {
+		return err
+	}
+	remote, err := u.Provider.GetAttributes(opt)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case local.Size != remote.Size:
+		return fmt.Errorf("size mismatch: local=[%d] remote=[%d]", local.Size, remote.Size)
+	case remote.MD5 != "" && local.MD5 != remote.MD5:
+		return fmt.Errorf("MD5 mismatch: local=[%s] remote=[%s]", local.MD5, remote.MD5)
+	case remote.CRC32C != "" && local.CRC32C != remote.CRC32C:
+		return fmt.Errorf("CRC32C mismatch: local=[%s] remote=[%s]", local.CRC32C, remote.CRC32C)
+	}
+	return nil
+}
+
+func (u *Uploader) addManifest(entry manifestEntry) error {
+	if err := entry.setMD5(); err != nil {
+		return err
+	}
+	return u.Manifest.add(entry)
+}
+
+// DeleteOrphanObjects deletes objects under the prefix which do not exist in the local dir.
+// The prefix must not be empty, to avoid deleting all objects in the bucket.
+func (u *Uploader) DeleteOrphanObjects() error {
+	listPrefix := getListPrefix(u.PathPrefix)
+	if listPrefix == "" {
+		return fmt.Errorf("cannot delete objects without prefix")
+	}
+
+	objects, err := u.Provider.List(provider.ListOption{
+		BucketName: u.Bucket,
+		Prefix:     listPrefix,
 	})
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if _, ok := u.localObjects[obj.Path]; ok {
+			continue
+		}
+		if !u.FileTypes.isTarget(obj.Path) {
+			continue
+		}
+
+		if u.DryRun {
+			u.plans.add(uploadPlan{
+				Operation:  planDelete,
+				ObjectPath: obj.Path,
+				Reason:     "not exist in local",
+			})
+			continue
+		}
+
+		fmt.Printf("[DELETE] object=[%s]\n", obj.Path)
+		err := u.Retry.Do(func() error {
+			return u.Provider.Delete(provider.FileOption{
+				BucketName: u.Bucket,
+				DstPath:    obj.Path,
+			})
+		}, u.Provider.IsRetryableError)
+		if err != nil {
+			fmt.Printf("[ERROR]: object=[%s] error=[%s]\n", obj.Path, err.Error())
+			u.result.addFailure("", obj.Path, err)
+			continue
+		}
+		u.result.addDeleted()
+		if u.Manifest != nil {
+			if err := u.Manifest.remove(u.Bucket, obj.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// plan decides the operation for the file without uploading.
+func (u *Uploader) plan(dir, fileName string, isDuplicate bool) uploadPlan {
+	srcPath := filepath.Join(dir, fileName)
+	p := uploadPlan{
+		Operation:  planUpload,
+		Path:       srcPath,
+		ObjectPath: u.getObjectPath(dir, fileName),
+	}
+	if isDuplicate {
+		p.Operation = planConflict
+		p.Reason = "duplicate object path"
+		return p
+	}
+
+	if u.Manifest != nil {
+		entry, err := newManifestEntry(srcPath, u.Bucket, p.ObjectPath)
+		if err != nil {
+			p.Operation = planConflict
+			p.Reason = err.Error()
+			return p
+		}
+
+		if prev, ok := u.Manifest.get(entry.key()); ok {
+			isSame, err := entry.isSame(prev)
+			switch {
+			case err != nil:
+				p.Operation = planConflict
+				p.Reason = err.Error()
+			case u.Resume, isSame:
+				p.Operation = planSkip
+				p.Reason = "unchanged in manifest"
+			default:
+				p.Reason = "modified from manifest"
+			}
+			return p
+		}
+	}
+
+	if !u.CheckExists {
+		return p
+	}
+
+	opt := provider.FileOption{
+		SrcPath:    srcPath,
+		BucketName: u.Bucket,
+		DstPath:    p.ObjectPath,
+	}
+	ok, err := u.Provider.IsExists(opt)
+	switch {
+	case err != nil:
+		p.Operation = planConflict
+		p.Reason = err.Error()
+		return p
+	case !ok:
+		p.Reason = "not exist in bucket"
+		return p
+	}
+
+	// the existing object is skipped on upload, but report it as conflict when the content differs.
+	if err := u.verify(opt); err != nil {
+		p.Operation = planConflict
+		p.Reason = err.Error()
+		return p
+	}
+	p.Operation = planSkip
+	p.Reason = "already exists"
+	return p
+}
+
+// addLocalObject adds the object path and returns true if it's already added.
+func (u *Uploader) addLocalObject(objectPath string) (isDuplicate bool) {
+	if u.localObjects == nil {
+		u.localObjects = make(map[string]struct{})
+	}
+	if _, ok := u.localObjects[objectPath]; ok {
+		return true
+	}
+	u.localObjects[objectPath] = struct{}{}
+	return false
+}
+
+func (u *Uploader) getObjectPath(dir, fileName string) string {
+	return path.Join(u.PathPrefix, u.getLabel(dir), fileName)
 }
 
 func (u *Uploader) getLabel(path string) string {
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// fakeProvider counts calls and keeps uploaded object paths in memory.
+type fakeProvider struct {
+	objects      map[string]bool
+	existsCalls  int
+	uploadCalls  int
+	deletedPaths []string
+}
+
+func newFakeProvider(objectPaths ...string) *fakeProvider {
+	p := &fakeProvider{objects: make(map[string]bool)}
+	for _, o := range objectPaths {
+		p.objects[o] = true
+	}
+	return p
+}
+
+func (p *fakeProvider) CheckBucket(bucketName string) error { return nil }
+
+func (p *fakeProvider) IsExists(opt provider.FileOption) (bool, error) {
+	p.existsCalls++
+	return p.objects[opt.DstPath], nil
+}
+
+func (p *fakeProvider) UploadFromLocalFile(opt provider.FileOption) error {
+	p.uploadCalls++
+	p.objects[opt.DstPath] = true
+	return nil
+}
+
+func (p *fakeProvider) List(opt provider.ListOption) ([]provider.Object, error) {
+	list := make([]provider.Object, 0, len(p.objects))
+	for o := range p.objects {
+		list = append(list, provider.Object{Path: o})
+	}
+	return list, nil
+}
+
+func (p *fakeProvider) Download(opt provider.FileOption) error { return nil }
+
+func (p *fakeProvider) GetAttributes(opt provider.FileOption) (provider.Object, error) {
+	return provider.Object{Path: opt.DstPath}, nil
+}
+
+func (p *fakeProvider) Delete(opt provider.FileOption) error {
+	p.deletedPaths = append(p.deletedPaths, opt.DstPath)
+	delete(p.objects, opt.DstPath)
+	return nil
+}
+
+func (p *fakeProvider) IsRetryableError(error) bool { return false }
+
+func newTestUploader(t *testing.T, p *fakeProvider, resume bool) (*Uploader, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "cat"), 0700); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cat", "1.jpg"), []byte("abc"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, err := newUploadManifest(filepath.Join(dir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { m.Close() }) //nolint
+	return &Uploader{
+		Provider:   p,
+		Bucket:     "bucket",
+		PathPrefix: "prefix",
+		BaseDir:    dir + "/",
+		Manifest:   m,
+		Resume:     resume,
+	}, dir
+}
+
+func TestUploaderUploadWithManifest(t *testing.T) {
+	p := newFakeProvider()
+	u, dir := newTestUploader(t, p, false)
+	catDir := filepath.Join(dir, "cat")
+
+	skip, err := u.upload(catDir, "1.jpg")
+	if err != nil || skip {
+		t.Fatalf("expected upload, but got skip=[%v] err=[%v]", skip, err)
+	}
+
+	// unchanged file is skipped without network call.
+	skip, err = u.upload(catDir, "1.jpg")
+	if err != nil || !skip {
+		t.Fatalf("expected skip, but got skip=[%v] err=[%v]", skip, err)
+	}
+	if p.existsCalls != 1 || p.uploadCalls != 1 {
+		t.Errorf("unexpected calls: exists=[%d] upload=[%d]", p.existsCalls, p.uploadCalls)
+	}
+
+	// modified file is uploaded again.
+	if err := os.WriteFile(filepath.Join(catDir, "1.jpg"), []byte("abcd"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	skip, err = u.upload(catDir, "1.jpg")
+	if err != nil || skip {
+		t.Fatalf("expected upload, but got skip=[%v] err=[%v]", skip, err)
+	}
+	if p.uploadCalls != 2 {
+		t.Errorf("expected 2 uploads, but got %d", p.uploadCalls)
+	}
+}
+
+func TestUploaderUploadExistingObject(t *testing.T) {
+	p := newFakeProvider("prefix/cat/1.jpg")
+	u, dir := newTestUploader(t, p, false)
+	catDir := filepath.Join(dir, "cat")
+
+	for i := 0; i < 2; i++ {
+		skip, err := u.upload(catDir, "1.jpg")
+		if err != nil || !skip {
+			t.Fatalf("expected skip, but got skip=[%v] err=[%v]", skip, err)
+		}
+	}
+	// the existing object is recorded into the manifest on the first run.
+	if p.existsCalls != 1 || p.uploadCalls != 0 {
+		t.Errorf("unexpected calls: exists=[%d] upload=[%d]", p.existsCalls, p.uploadCalls)
+	}
+	if _, ok := u.Manifest.get("bucket/prefix/cat/1.jpg"); !ok {
+		t.Errorf("existing object is not recorded")
+	}
+}
+
+func TestUploaderUploadResume(t *testing.T) {
+	p := newFakeProvider()
+	u, dir := newTestUploader(t, p, true)
+	catDir := filepath.Join(dir, "cat")
+
+	if _, err := u.upload(catDir, "1.jpg"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// --resume skips files in the manifest even if they are modified.
+	if err := os.WriteFile(filepath.Join(catDir, "1.jpg"), []byte("abcd"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	skip, err := u.upload(catDir, "1.jpg")
+	if err != nil || !skip {
+		t.Fatalf("expected skip, but got skip=[%v] err=[%v]", skip, err)
+	}
+	if p.existsCalls != 1 || p.uploadCalls != 1 {
+		t.Errorf("unexpected calls: exists=[%d] upload=[%d]", p.existsCalls, p.uploadCalls)
+	}
+}
+
+func TestUploadRunnerSyncWithoutPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "/", "//"} {
+		r := UploadRunner{
+			Input:      t.TempDir(),
+			PathPrefix: prefix,
+			Sync:       true,
+		}
+		err := r.Run()
+		if err == nil || !strings.Contains(err.Error(), "--sync") {
+			t.Errorf("prefix=[%s]: expected error of --sync, but got [%v]", prefix, err)
+		}
+	}
+}
+
+func TestUploaderDeleteOrphanObjects(t *testing.T) {
+	t.Run("without prefix", func(t *testing.T) {
+		p := newFakeProvider("cat/1.jpg")
+		u := &Uploader{Provider: p, PathPrefix: "/", FileTypes: newFileType([]string{"jpg"})}
+		if err := u.DeleteOrphanObjects(); err == nil {
+			t.Errorf("expected error")
+		}
+		if len(p.deletedPaths) != 0 {
+			t.Errorf("objects are deleted: %v", p.deletedPaths)
+		}
+	})
+
+	t.Run("with prefix", func(t *testing.T) {
+		p := newFakeProvider("prefix/cat/1.jpg", "prefix/cat/2.jpg")
+		u := &Uploader{Provider: p, PathPrefix: "prefix", FileTypes: newFileType([]string{"jpg"})}
+		u.addLocalObject("prefix/cat/1.jpg")
+		if err := u.DeleteOrphanObjects(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(p.deletedPaths) != 1 || p.deletedPaths[0] != "prefix/cat/2.jpg" {
+			t.Errorf("unexpected deleted objects: %v", p.deletedPaths)
+		}
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/mkideal/cli"
@@ -19,6 +18,8 @@ type vottT struct {
 	Output      string `cli:"*o,output" usage:"output CSV file path --output='./output.csv'" dft:"./output.csv"`
 	PathPrefix  string `cli:"*p,prefix" usage:"prefix for file path --prefix='gs://<your-bucket-name>'" dft:"gs://"`
 	IsRecursive bool   `cli:"r,recursive" usage:"read files in sub directories" dft:"false"`
+	Format      string `cli:"f,format" usage:"set output format --format='[automl-detection,vertex-detection,vertex-classification,coco,voc]'" dft:"automl-detection"`
+	SplitOption
 }
 
 var vott = &cli.Command{
@@ -32,6 +33,17 @@ func execVott(ctx *cli.Context) error {
 	argv := ctx.Argv().(*vottT)
 
 	r := newVottRunner(*argv)
+	splitter, err := newDatasetSplitter(argv.SplitOption)
+	if err != nil {
+		return err
+	}
+	r.Splitter = splitter
+
+	writer, err := createVottFormat(argv.Format)
+	if err != nil {
+		return err
+	}
+	r.Writer = writer
 	return r.Run()
 }
 
@@ -41,8 +53,10 @@ type VottRunner struct {
 	Output      string
 	PathPrefix  string
 	IsRecursive bool
+	SplitFiles  bool
 
-	Formatter formatter
+	Writer   recordWriter
+	Splitter datasetSplitter
 }
 
 func newVottRunner(p vottT) VottRunner {
@@ -51,19 +65,15 @@ func newVottRunner(p vottT) VottRunner {
 		Output:      p.Output,
 		PathPrefix:  p.PathPrefix,
 		IsRecursive: p.IsRecursive,
+		SplitFiles:  p.SplitFiles,
 	}
 }
 
 func (r *VottRunner) Run() error {
 	// try to open before starting process
-	f, err := NewFileHandler(r.Output)
-	if err != nil {
+	if _, err := NewFileHandler(r.Output); err != nil {
 		return err
 	}
-	r.Formatter = &automlObjectDetectionFormatter{
-		pathPrefix: r.PathPrefix,
-	}
-
 	// get json file list
 	baseDir = fmt.Sprintf("%s/", filepath.Clean(r.InputDir))
 	jsonFiles, err := r.FindJSONFilesFromDir(baseDir)
@@ -71,14 +81,14 @@ func (r *VottRunner) Run() error {
 		return err
 	}
 
-	// read VoTT JSON and convert to AutoML format.
-	results, err := r.ReadDataFromJSONFiles(jsonFiles)
+	// read VoTT JSON and convert to the format.
+	records, err := r.ReadDataFromJSONFiles(jsonFiles)
 	if err != nil {
 		return err
 	}
 
-	// save to CSV file
-	return f.WriteAll(results)
+	// save to the file
+	return writeRecords(r.Writer, r.Output, records, r.SplitFiles)
 }
 
 func (r VottRunner) FindJSONFilesFromDir(dir string) ([]string, error) {
@@ -110,8 +120,11 @@ func (r VottRunner) FindJSONFilesFromDir(dir string) ([]string, error) {
 	return list, nil
 }
 
-func (r VottRunner) ReadDataFromJSONFiles(list []string) ([]string, error) {
-	var results []string
+// ReadDataFromJSONFiles reads VoTT JSON files and returns records of the images.
+// Split is assigned per image.
+func (r VottRunner) ReadDataFromJSONFiles(list []string) ([]labelRecord, error) {
+	dataList := make([]VottFormat, 0, len(list))
+	items := make([]splitItem, 0, len(list))
 	for _, path := range list {
 		byt, err := os.ReadFile(path)
 		if err != nil {
@@ -125,21 +138,38 @@ func (r VottRunner) ReadDataFromJSONFiles(list []string) ([]string, error) {
 		if !data.HasValidBoundingBox() {
 			fmt.Printf("[WARN] invalid bounding box: [%s]\n", path)
 		}
+		if len(data.Regions) != 0 && !data.Asset.Size.IsValid() {
+			fmt.Printf("[WARN] bounding boxes are skipped for zero image size: [%s], width=[%d], height=[%d]\n", path, data.Asset.Size.Width, data.Asset.Size.Height)
+		}
 
-		w := data.Asset.Size.Width
-		h := data.Asset.Size.Height
-		for _, reg := range data.Regions {
-			tagName, points := reg.FullVertices(w, h)
-			if tagName == "" {
-				continue
-			}
+		// use the path of JSON file for split by dir, and the image name for split by hash.
+		key := data.Asset.Name
+		if r.Splitter.isDirMethod() {
+			key = strings.TrimPrefix(path, baseDir)
+		}
+		dataList = append(dataList, data)
+		items = append(items, splitItem{
+			key:   key,
+			label: data.FirstTag(),
+		})
+	}
 
-			// e.g. label,0.1,0.1,0.1,0.2,0.2,0.2,0.2,0.1
-			labelData := strings.Join(append([]string{tagName}, points...), ",")
-			results = append(results, r.Formatter.format(data.Asset.Name, labelData))
+	splits := r.Splitter.assign(items)
+	records := make([]labelRecord, len(dataList))
+	for i, data := range dataList {
+		records[i] = labelRecord{
+			path:   r.PathPrefix + data.Asset.Name,
+			name:   data.Asset.Name,
+			labels: data.Tags(),
+			boxes:  data.BoundingBoxes(),
+			split:  splits[i],
+			metadata: imageMetadata{
+				width:  data.Asset.Size.Width,
+				height: data.Asset.Size.Height,
+			},
 		}
 	}
-	return results, nil
+	return records, nil
 }
 
 // type mappings for VoTT JSON
@@ -149,6 +179,45 @@ type VottFormat struct {
 	Regions []vottRegion `json:"regions"`
 }
 
+// FirstTag returns the first tag of the regions.
+func (v VottFormat) FirstTag() string {
+	for _, r := range v.Regions {
+		if len(r.Tags) != 0 {
+			return r.Tags[0]
+		}
+	}
+	return ""
+}
+
+// Tags returns unique tags of the regions in order of appearance.
+func (v VottFormat) Tags() []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, r := range v.Regions {
+		for _, t := range r.Tags {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// BoundingBoxes returns normalized bounding boxes of the regions with tag.
+func (v VottFormat) BoundingBoxes() []boundingBox {
+	w := v.Asset.Size.Width
+	h := v.Asset.Size.Height
+	boxes := make([]boundingBox, 0, len(v.Regions))
+	for _, reg := range v.Regions {
+		if box, ok := reg.NormalizedBox(w, h); ok {
+			boxes = append(boxes, box)
+		}
+	}
+	return boxes
+}
+
 func (v VottFormat) HasValidBoundingBox() bool {
 	for _, r := range v.Regions {
 		if len(r.Points) < 2 {
@@ -193,6 +262,11 @@ type vottSize struct {
 	Height int64 `json:"height"`
 }
 
+// IsValid returns false for zero or missing size.
+func (s vottSize) IsValid() bool {
+	return s.Width > 0 && s.Height > 0
+}
+
 type vottRegion struct {
 	ID          string          `json:"id"`
 	Type        string          `json:"type"`
@@ -201,11 +275,16 @@ type vottRegion struct {
 	Points      []vottPoint     `json:"points"`
 }
 
-func (v vottRegion) FullVertices(width, height int64) (tagName string, vertices []string) {
-	if len(v.Tags) == 0 {
-		return "", nil
+// region type of VoTT for polygon.
+const vottRegionPolygon = "POLYGON"
+
+// NormalizedBox returns normalized bounding box of the points with the first tag.
+// Vertices of polygon region are kept in the box.
+// It returns false for zero image size, as the box cannot be normalized.
+func (v vottRegion) NormalizedBox(width, height int64) (box boundingBox, ok bool) {
+	if len(v.Tags) == 0 || !(vottSize{Width: width, Height: height}).IsValid() {
+		return box, false
 	}
-	tagName = v.Tags[0]
 
 	minX, minY, maxX, maxY := -1.0, -1.0, -1.0, -1.0
 	for _, p := range v.Points {
@@ -223,18 +302,22 @@ func (v vottRegion) FullVertices(width, height int64) (tagName string, vertices
 		}
 	}
 
-	// output: [(x1,y1), (x2,y1), (x2,y2), (x1,y2)]
-	const verticesSize = 4 * 2
-	results := make([]string, verticesSize)
-	results[0] = strconv.FormatFloat(minX/float64(width), 'f', -1, 64)
-	results[1] = strconv.FormatFloat(minY/float64(height), 'f', -1, 64)
-	results[2] = strconv.FormatFloat(maxX/float64(width), 'f', -1, 64)
-	results[3] = strconv.FormatFloat(minY/float64(height), 'f', -1, 64)
-	results[4] = strconv.FormatFloat(maxX/float64(width), 'f', -1, 64)
-	results[5] = strconv.FormatFloat(maxY/float64(height), 'f', -1, 64)
-	results[6] = strconv.FormatFloat(minX/float64(width), 'f', -1, 64)
-	results[7] = strconv.FormatFloat(maxY/float64(height), 'f', -1, 64)
-	return tagName, results
+	box = boundingBox{
+		label: v.Tags[0],
+		xMin:  minX / float64(width),
+		yMin:  minY / float64(height),
+		xMax:  maxX / float64(width),
+		yMax:  maxY / float64(height),
+	}
+	if v.Type == vottRegionPolygon {
+		for _, p := range v.Points {
+			box.polygon = append(box.polygon, point{
+				x: p.X / float64(width),
+				y: p.Y / float64(height),
+			})
+		}
+	}
+	return box, true
 }
 
 type vottBoundingBox struct {
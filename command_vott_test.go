@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVottRegionNormalizedBox(t *testing.T) {
+	rect := vottRegion{
+		Type:   "RECTANGLE",
+		Tags:   []string{"cat", "animal"},
+		Points: []vottPoint{{X: 10, Y: 20}, {X: 50, Y: 20}, {X: 50, Y: 80}, {X: 10, Y: 80}},
+	}
+	polygon := vottRegion{
+		Type:   vottRegionPolygon,
+		Tags:   []string{"dog"},
+		Points: []vottPoint{{X: 20, Y: 0}, {X: 100, Y: 50}, {X: 0, Y: 100}},
+	}
+
+	tests := []struct {
+		name     string
+		region   vottRegion
+		width    int64
+		height   int64
+		expected boundingBox
+		ok       bool
+	}{
+		{"rectangle", rect, 100, 200, boundingBox{label: "cat", xMin: 0.1, yMin: 0.1, xMax: 0.5, yMax: 0.4}, true},
+		{"polygon", polygon, 100, 100, boundingBox{
+			label: "dog", xMin: 0, yMin: 0, xMax: 1, yMax: 1,
+			polygon: []point{{x: 0.2, y: 0}, {x: 1, y: 0.5}, {x: 0, y: 1}},
+		}, true},
+		{"no tag", vottRegion{Points: rect.Points}, 100, 200, boundingBox{}, false},
+		{"zero width", rect, 0, 200, boundingBox{}, false},
+		{"zero height", rect, 100, 0, boundingBox{}, false},
+		{"missing size", polygon, 0, 0, boundingBox{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, ok := tt.region.NormalizedBox(tt.width, tt.height)
+			if ok != tt.ok {
+				t.Fatalf("expected [%t], but got [%t]", tt.ok, ok)
+			}
+			if !reflect.DeepEqual(box, tt.expected) {
+				t.Errorf("expected %+v, but got %+v", tt.expected, box)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// size of the data to sniff content type.
+const sniffLen = 512
+
+const unknownContentType = "application/octet-stream"
+
+// contentTypeFilter checks the content type is allowed.
+type contentTypeFilter struct {
+	types map[string]struct{}
+}
+
+// newContentTypeFilter returns contentTypeFilter.
+// wildcard can be used for sub type. (e.g. 'image/*')
+func newContentTypeFilter(list []string) contentTypeFilter {
+	types := make(map[string]struct{})
+	for _, s := range list {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		types[s] = struct{}{}
+	}
+	return contentTypeFilter{
+		types: types,
+	}
+}
+
+func (f contentTypeFilter) isEmpty() bool {
+	return len(f.types) == 0
+}
+
+func (f contentTypeFilter) isAllowed(mediaType string) bool {
+	if _, ok := f.types[mediaType]; ok {
+		return true
+	}
+	if i := strings.Index(mediaType, "/"); i != -1 {
+		_, ok := f.types[mediaType[:i]+"/*"]
+		return ok
+	}
+	return false
+}
+
+// validate checks both of Content-Type header and sniffed type from the head of the data.
+func (f contentTypeFilter) validate(header string, head []byte) error {
+	if f.isEmpty() {
+		return nil
+	}
+
+	headerType := getMediaType(header)
+	sniffedType := getMediaType(http.DetectContentType(head))
+	switch {
+	case headerType == "" && sniffedType == "":
+		return fmt.Errorf("unknown content type")
+	case headerType != "" && !f.isAllowed(headerType):
+		return fmt.Errorf("content type is not allowed: header=[%s]", headerType)
+	case sniffedType != "" && !f.isAllowed(sniffedType):
+		return fmt.Errorf("content type is not allowed: sniffed=[%s]", sniffedType)
+	}
+	return nil
+}
+
+// getMediaType returns media type without parameters, and returns empty string for unknown type.
+// (e.g.) 'text/html; charset=utf-8' => 'text/html'
+func getMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == unknownContentType {
+		return ""
+	}
+	return strings.ToLower(mediaType)
+}
+
+// file extensions of content types.
+// mime.ExtensionsByType is not used, as the result depends on OS. (e.g. '.jpe' for 'image/jpeg')
+var contentTypeExt = map[string]string{
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/gif":        ".gif",
+	"image/webp":       ".webp",
+	"image/bmp":        ".bmp",
+	"image/tiff":       ".tiff",
+	"image/svg+xml":    ".svg",
+	"image/x-icon":     ".ico",
+	"video/mp4":        ".mp4",
+	"video/webm":       ".webm",
+	"video/quicktime":  ".mov",
+	"video/x-msvideo":  ".avi",
+	"audio/mpeg":       ".mp3",
+	"audio/wave":       ".wav",
+	"audio/wav":        ".wav",
+	"audio/ogg":        ".ogg",
+	"application/ogg":  ".ogg",
+	"application/pdf":  ".pdf",
+	"application/zip":  ".zip",
+	"application/json": ".json",
+	"text/plain":       ".txt",
+	"text/csv":         ".csv",
+	"text/html":        ".html",
+	"text/xml":         ".xml",
+	"application/xml":  ".xml",
+}
+
+// detectContentType returns media type from Content-Type header,
+// or sniffed type from the head of the data when the header is unknown type.
+func detectContentType(header string, head []byte) string {
+	if t := getMediaType(header); t != "" {
+		if _, ok := contentTypeExt[t]; ok {
+			return t
+		}
+	}
+	return getMediaType(http.DetectContentType(head))
+}
+
+// getExtByContentType returns file extension of the content type, or empty string for unknown type.
+func getExtByContentType(contentType string) string {
+	return contentTypeExt[contentType]
+}
+
+// isKnownExt returns true when the extension is returned by getExtByContentType.
+func isKnownExt(ext string) bool {
+	for _, v := range contentTypeExt {
+		if v == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// getKnownExts returns file extensions for getExtByContentType.
+func getKnownExts() []string {
+	seen := make(map[string]struct{})
+	exts := make([]string, 0, len(contentTypeExt))
+	for _, ext := range contentTypeExt {
+		if _, ok := seen[ext]; ok {
+			continue
+		}
+		seen[ext] = struct{}{}
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
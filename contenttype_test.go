@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+)
+
+var (
+	testJPEGHead = []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00")
+	testPNGHead  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	testHTMLHead = []byte("<!DOCTYPE html><html><body>not found</body></html>")
+)
+
+func TestContentTypeFilterIsAllowed(t *testing.T) {
+	f := newContentTypeFilter([]string{"image/*", " Video/MP4 ", ""})
+
+	tests := []struct {
+		mediaType string
+		expected  bool
+	}{
+		{"image/jpeg", true},
+		{"image/png", true},
+		{"video/mp4", true},
+		{"video/webm", false},
+		{"text/html", false},
+		{"application/json", false},
+		{"image", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			if v := f.isAllowed(tt.mediaType); v != tt.expected {
+				t.Errorf("expected [%t], but got [%t]", tt.expected, v)
+			}
+		})
+	}
+
+	if !newContentTypeFilter([]string{"", " "}).isEmpty() {
+		t.Errorf("expected empty filter")
+	}
+}
+
+func TestContentTypeFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    []string
+		header  string
+		head    []byte
+		isValid bool
+	}{
+		{"empty filter", nil, "text/html", testHTMLHead, true},
+		{"exact", []string{"image/jpeg"}, "image/jpeg", testJPEGHead, true},
+		{"exact with other type", []string{"image/png"}, "image/jpeg", testJPEGHead, false},
+		{"wildcard", []string{"image/*"}, "image/png", testPNGHead, true},
+		{"wildcard with other type", []string{"video/*"}, "image/png", testPNGHead, false},
+		{"parameters", []string{"text/html"}, "text/html; charset=utf-8", testHTMLHead, true},
+		{"upper case with parameters", []string{"image/*"}, "Image/JPEG; foo=bar", testJPEGHead, true},
+		{"no header", []string{"image/*"}, "", testJPEGHead, true},
+		{"octet-stream header", []string{"image/*"}, "application/octet-stream", testPNGHead, true},
+		{"invalid header", []string{"image/*"}, "image/", testPNGHead, true},
+		{"html error page as image", []string{"image/*"}, "image/jpeg", testHTMLHead, false},
+		{"html header with image", []string{"image/*"}, "text/html; charset=utf-8", testJPEGHead, false},
+		{"unknown", []string{"image/*"}, "", []byte{0x00, 0x01, 0x02}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newContentTypeFilter(tt.list).validate(tt.header, tt.head)
+			switch {
+			case tt.isValid && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case !tt.isValid && err == nil:
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		head     []byte
+		expected string
+	}{
+		{"header", "image/jpeg", testJPEGHead, "image/jpeg"},
+		{"header with parameters", "Image/PNG; charset=binary", testPNGHead, "image/png"},
+		// the header is used for the type which is not sniffed. (e.g. 'video/quicktime')
+		{"header of unsniffable type", "video/quicktime", []byte{0x00, 0x00, 0x00, 0x14}, "video/quicktime"},
+		{"no header", "", testPNGHead, "image/png"},
+		{"octet-stream header", "application/octet-stream", testJPEGHead, "image/jpeg"},
+		{"header without extension", "image/x-foo", testJPEGHead, "image/jpeg"},
+		{"invalid header", "image/", testJPEGHead, "image/jpeg"},
+		{"html", "", testHTMLHead, "text/html"},
+		{"unknown", "", []byte{0x00, 0x01, 0x02}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := detectContentType(tt.header, tt.head); v != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestGetExtByContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    string
+	}{
+		{"image/jpeg", ".jpg"},
+		{"image/png", ".png"},
+		{"image/svg+xml", ".svg"},
+		{"video/quicktime", ".mov"},
+		{"audio/wave", ".wav"},
+		{"audio/wav", ".wav"},
+		{"text/xml", ".xml"},
+		{"application/xml", ".xml"},
+		{"application/octet-stream", ""},
+		{"image/x-foo", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			ext := getExtByContentType(tt.contentType)
+			if ext != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, ext)
+			}
+			if ext != "" && !isKnownExt(ext) {
+				t.Errorf("expected known extension: [%s]", ext)
+			}
+		})
+	}
+
+	// known extensions are sorted without duplicates.
+	exts := getKnownExts()
+	for i := 1; i < len(exts); i++ {
+		if exts[i-1] >= exts[i] {
+			t.Errorf("expected sorted unique extensions, but got %v", exts)
+			break
+		}
+	}
+}
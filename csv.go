@@ -1,22 +1,26 @@
 package main
 
 import (
-	"encoding/csv"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 )
 
-// CSVHandler handles CSV file.
+// CSVHandler handles rows of input file. (CSV, TSV, JSON Lines or Parquet)
 type CSVHandler struct {
 	header    []string
 	headerMap map[string]int
-	reader    *csv.Reader
+	reader    rowReader
+	fp        *os.File
 }
 
-// NewCSVHandler returns initialized *CSVHandler
+// NewCSVHandler returns initialized *CSVHandler, and the file format is detected from the extension.
 func NewCSVHandler(file string) (*CSVHandler, error) {
+	return NewCSVHandlerWithFormat(file, "")
+}
+
+// NewCSVHandlerWithFormat returns initialized *CSVHandler for the file format. (csv, tsv, jsonl or parquet)
+func NewCSVHandlerWithFormat(file, format string) (*CSVHandler, error) {
 	file = filepath.Clean(file)
 	info, err := os.Stat(file)
 	if err == nil && info.IsDir() {
@@ -29,19 +33,16 @@ func NewCSVHandler(file string) (*CSVHandler, error) {
 		return nil, err
 	}
 
-	// load csv
-	reader := csv.NewReader(fp)
-	reader.LazyQuotes = true
-	switch filepath.Ext(file) {
-	case ".tsv":
-		reader.Comma = '\t'
+	if format == "" {
+		format = getInputFormat(file)
 	}
-
-	header, err := reader.Read()
+	reader, err := newRowReader(fp, format)
 	if err != nil {
+		fp.Close() //nolint:errcheck
 		return nil, err
 	}
 
+	header := reader.header()
 	headerMap := make(map[string]int)
 	for i, col := range header {
 		headerMap[col] = i
@@ -51,29 +52,25 @@ func NewCSVHandler(file string) (*CSVHandler, error) {
 		header:    header,
 		headerMap: headerMap,
 		reader:    reader,
+		fp:        fp,
 	}, nil
 }
 
-// Read reads a line from CSV file.
+// Read reads a row from the file.
 func (f *CSVHandler) Read() (map[string]string, error) {
 	if f.reader == nil {
 		return nil, fmt.Errorf("f.reader is nil")
 	}
+	return f.reader.read()
+}
 
-	line, err := f.reader.Read()
-	switch {
-	case err == io.EOF:
-		return nil, nil
-	case err != nil:
-		return nil, err
-	}
-
-	header := f.header
-	result := make(map[string]string)
-	for i, col := range line {
-		result[header[i]] = col
+// Close closes the reader and the file.
+func (f *CSVHandler) Close() error {
+	if err := f.reader.close(); err != nil {
+		f.fp.Close() //nolint:errcheck
+		return err
 	}
-	return result, nil
+	return f.fp.Close()
 }
 
 // checkHeaders checks header columns.
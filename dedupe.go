@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// modes for duplicate files on download.
+const (
+	dedupeSkip    = "skip"
+	dedupeSymlink = "symlink"
+)
+
+func validateDedupeMode(mode string) error {
+	switch mode {
+	case dedupeSkip, dedupeSymlink:
+		return nil
+	}
+	return fmt.Errorf("unknown dedupe mode: [%s]", mode)
+}
+
+// hashIndex is content hash index of downloaded files to find duplicates.
+// path is relative path from baseDir.
+// It also has URLs of the duplicate files to skip them without download on the next run.
+type hashIndex struct {
+	baseDir string
+
+	dataMu sync.RWMutex
+	data   map[string]string // hash => path
+	urls   map[string]string // url of duplicate => hash
+}
+
+func newHashIndex(baseDir string) *hashIndex {
+	return &hashIndex{
+		baseDir: baseDir,
+		data:    make(map[string]string),
+		urls:    make(map[string]string),
+	}
+}
+
+// loadHashIndex reads the persisted index file, and returns empty index when the file does not exist.
+func loadHashIndex(baseDir, file string) (*hashIndex, error) {
+	idx := newHashIndex(baseDir)
+	if !isFileExist(file) {
+		return idx, nil
+	}
+
+	f, err := NewCSVHandler(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	if err := f.checkHeaders("md5", "path"); err != nil {
+		return nil, err
+	}
+	for {
+		line, err := f.Read()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return idx, nil
+		}
+		idx.data[line["md5"]] = line["path"]
+		if url := line["url"]; url != "" {
+			idx.urls[url] = line["md5"]
+		}
+	}
+}
+
+// add adds the file into the index, and returns the original path when the hash already exists.
+// The entry is replaced when the original file no longer exists.
+func (idx *hashIndex) add(hash, path string) (original string, isDuplicate bool) {
+	idx.dataMu.Lock()
+	defer idx.dataMu.Unlock()
+
+	original, ok := idx.data[hash]
+	if ok && original != path && isFileExist(filepath.Join(idx.baseDir, original)) {
+		return original, true
+	}
+	idx.data[hash] = path
+	return "", false
+}
+
+// addURL adds the URL of the duplicate file.
+func (idx *hashIndex) addURL(url, hash string) {
+	idx.dataMu.Lock()
+	defer idx.dataMu.Unlock()
+	idx.urls[url] = hash
+}
+
+// findURL returns the path of the original file when the URL was a duplicate of it,
+// and the original file still exists.
+func (idx *hashIndex) findURL(url string) (original string, ok bool) {
+	idx.dataMu.RLock()
+	defer idx.dataMu.RUnlock()
+
+	hash, ok := idx.urls[url]
+	if !ok {
+		return "", false
+	}
+	original, ok = idx.data[hash]
+	if !ok || !isFileExist(filepath.Join(idx.baseDir, original)) {
+		return "", false
+	}
+	return original, true
+}
+
+// WriteFile writes the index sorted by path into CSV file.
+// Rows of the original files have empty url, and rows of the duplicate URLs have the path of the original file.
+func (idx *hashIndex) WriteFile(file string) error {
+	idx.dataMu.RLock()
+	defer idx.dataMu.RUnlock()
+
+	rows := make([][]string, 0, len(idx.data)+len(idx.urls))
+	for hash, path := range idx.data {
+		rows = append(rows, []string{hash, path, ""})
+	}
+	for url, hash := range idx.urls {
+		if path, ok := idx.data[hash]; ok {
+			rows = append(rows, []string{hash, path, url})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i][1] != rows[j][1] {
+			return rows[i][1] < rows[j][1]
+		}
+		return rows[i][2] < rows[j][2]
+	})
+
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"md5", "path", "url"})
+	_ = w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return provider.WriteFile(file, buf)
+}
+
+// replaceWithSymlink replaces the file with relative symlink to the original file.
+func replaceWithSymlink(filePath, original string) error {
+	target, err := filepath.Rel(filepath.Dir(filePath), original)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil {
+		return err
+	}
+	return os.Symlink(target, filePath)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashIndex(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "1.jpg"), []byte("a"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	idx := newHashIndex(dir)
+	if original, isDuplicate := idx.add("hash_a", "1.jpg"); isDuplicate {
+		t.Errorf("expected not duplicate, but got [%s]", original)
+	}
+	original, isDuplicate := idx.add("hash_a", "2.jpg")
+	if !isDuplicate || original != "1.jpg" {
+		t.Errorf("expected duplicate of [1.jpg], but got [%s]", original)
+	}
+	idx.addURL("http://example.com/2.jpg", "hash_a")
+	idx.addURL("http://example.com/3.jpg", "hash_b")
+
+	// the entry is replaced when the original file does not exist.
+	if original, isDuplicate := idx.add("hash_c", "4.jpg"); isDuplicate {
+		t.Errorf("expected not duplicate, but got [%s]", original)
+	}
+	if original, isDuplicate := idx.add("hash_c", "5.jpg"); isDuplicate {
+		t.Errorf("expected not duplicate, but got [%s]", original)
+	}
+
+	file := filepath.Join(dir, "index.csv")
+	if err := idx.WriteFile(file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := loadHashIndex(dir, file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+		ok       bool
+	}{
+		{"http://example.com/2.jpg", "1.jpg", true},
+		{"http://example.com/1.jpg", "", false},
+		{"http://example.com/3.jpg", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			original, ok := loaded.findURL(tt.url)
+			if ok != tt.ok || original != tt.expected {
+				t.Errorf("expected [%s, %t], but got [%s, %t]", tt.expected, tt.ok, original, ok)
+			}
+		})
+	}
+
+	// the URL is not skipped when the original file is deleted.
+	if err := os.Remove(filepath.Join(dir, "1.jpg")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if original, ok := loaded.findURL("http://example.com/2.jpg"); ok {
+		t.Errorf("expected not found, but got [%s]", original)
+	}
+}
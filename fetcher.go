@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// fetcher downloads a file from the URI, and returns detected content type of the file.
+type fetcher interface {
+	fetch(filePath string, typeFilter contentTypeFilter) (contentType string, err error)
+	isRetryableError(error) bool
+}
+
+// httpFetcher downloads a file from HTTP(S) URL.
+type httpFetcher struct {
+	client *httpClient
+	url    string
+}
+
+func (f httpFetcher) fetch(filePath string, typeFilter contentTypeFilter) (string, error) {
+	return downloadURL(f.client, f.url, filePath, typeFilter)
+}
+
+func (f httpFetcher) isRetryableError(err error) bool {
+	return isRetryableHTTPError(err)
+}
+
+// cloudFetcher downloads a file from the cloud bucket. (e.g. 's3://bucket/path/to/file.jpg')
+type cloudFetcher struct {
+	cli        provider.Provider
+	bucketName string
+	objectPath string
+}
+
+// fetch downloads the object into the temporary file in the same dir to validate the contents,
+// and renames it to filePath.
+func (f cloudFetcher) fetch(filePath string, typeFilter contentTypeFilter) (string, error) {
+	tmpPath := filepath.Join(filepath.Dir(filePath), "."+filepath.Base(filePath)+".download.tmp")
+	defer os.Remove(tmpPath) //nolint:errcheck
+	err := f.cli.Download(provider.FileOption{
+		SrcPath:    f.objectPath,
+		BucketName: f.bucketName,
+		DstPath:    tmpPath,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	head, err := readFileHead(tmpPath, sniffLen)
+	if err != nil {
+		return "", err
+	}
+	if err := typeFilter.validate("", head); err != nil {
+		return "", err
+	}
+	return detectContentType("", head), os.Rename(tmpPath, filePath)
+}
+
+func (f cloudFetcher) isRetryableError(err error) bool {
+	return f.cli.IsRetryableError(err)
+}
+
+// readFileHead reads the first n bytes of the file.
+func readFileHead(path string, n int) ([]byte, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close() //nolint:errcheck
+
+	head := make([]byte, n)
+	size, err := io.ReadFull(fp, head)
+	switch {
+	case size == 0:
+		return nil, errEmptyBody
+	case err != nil && err != io.ErrUnexpectedEOF:
+		return nil, err
+	}
+	return head[:size], nil
+}
+
+// providerMap caches Provider clients created from the URI scheme.
+type providerMap struct {
+	dataMu sync.Mutex
+	data   map[string]provider.Provider
+}
+
+func newProviderMap() providerMap {
+	return providerMap{
+		data: make(map[string]provider.Provider),
+	}
+}
+
+// newFetcher returns fetcher for the URI scheme, and returns error for unsupported scheme. (e.g. 'ftp://')
+// The clients for cloud buckets are created from env vars on the first use.
+func (m *providerMap) newFetcher(client *httpClient, uri string) (fetcher, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return httpFetcher{
+			client: client,
+			url:    uri,
+		}, nil
+	}
+
+	providerName, ok := provider.GetProviderNameByScheme(u.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("unsupported URL scheme: [%s]", u.Scheme)
+	}
+
+	cli, err := m.get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return cloudFetcher{
+		cli:        cli,
+		bucketName: u.Host,
+		objectPath: strings.TrimPrefix(u.Path, "/"),
+	}, nil
+}
+
+func (m *providerMap) get(providerName string) (provider.Provider, error) {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	if cli, ok := m.data[providerName]; ok {
+		return cli, nil
+	}
+	cli, err := provider.Create(providerName)
+	if err != nil {
+		return nil, err
+	}
+	m.data[providerName] = cli
+	return cli, nil
+}
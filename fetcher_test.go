@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+func TestProviderMapNewFetcher(t *testing.T) {
+	client, err := newHTTPClient(HTTPOption{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// cached clients are used not to create the clients from env vars.
+	s3Cli := newFakeProvider()
+	gcsCli := newFakeProvider()
+	m := newProviderMap()
+	m.data["s3"] = s3Cli
+	m.data["gcs"] = gcsCli
+
+	tests := []struct {
+		uri        string
+		cli        provider.Provider
+		bucketName string
+		objectPath string
+	}{
+		{"s3://my-bucket/path/to/cat.jpg", s3Cli, "my-bucket", "path/to/cat.jpg"},
+		{"S3://my-bucket/cat.jpg", s3Cli, "my-bucket", "cat.jpg"},
+		{"gs://my-bucket/path/to/cat.jpg", gcsCli, "my-bucket", "path/to/cat.jpg"},
+		{"https://example.com/cat.jpg?size=large", nil, "", ""},
+		{"http://example.com/cat.jpg", nil, "", ""},
+		{"HTTPS://example.com/cat.jpg", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			f, err := m.newFetcher(client, tt.uri)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			switch v := f.(type) {
+			case httpFetcher:
+				if tt.cli != nil {
+					t.Fatalf("expected cloudFetcher, but got httpFetcher")
+				}
+				if v.url != tt.uri {
+					t.Errorf("expected [%s], but got [%s]", tt.uri, v.url)
+				}
+				if v.client != client {
+					t.Errorf("expected the given http client")
+				}
+			case cloudFetcher:
+				if tt.cli == nil {
+					t.Fatalf("expected httpFetcher, but got cloudFetcher")
+				}
+				if v.cli != tt.cli {
+					t.Errorf("unexpected provider client for [%s]", tt.uri)
+				}
+				if v.bucketName != tt.bucketName {
+					t.Errorf("expected [%s], but got [%s]", tt.bucketName, v.bucketName)
+				}
+				if v.objectPath != tt.objectPath {
+					t.Errorf("expected [%s], but got [%s]", tt.objectPath, v.objectPath)
+				}
+			default:
+				t.Fatalf("unexpected fetcher: [%T]", f)
+			}
+		})
+	}
+}
+
+func TestProviderMapNewFetcherError(t *testing.T) {
+	m := newProviderMap()
+
+	tests := []string{
+		"ftp://example.com/cat.jpg",
+		"file:///tmp/cat.jpg",
+		"azblob://container/cat.jpg",
+		"/path/to/cat.jpg",
+		"cat.jpg",
+		"://example.com/cat.jpg",
+	}
+	for _, uri := range tests {
+		t.Run(uri, func(t *testing.T) {
+			if f, err := m.newFetcher(nil, uri); err == nil {
+				t.Errorf("expected error, but got [%T]", f)
+			}
+		})
+	}
+	if len(m.data) != 0 {
+		t.Errorf("expected no provider client, but got [%d]", len(m.data))
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// modes for file name collision between rows on download.
+const (
+	collisionReject = "reject"
+	collisionRename = "rename"
+)
+
+func validateCollisionMode(mode string) error {
+	switch mode {
+	case collisionReject, collisionRename:
+		return nil
+	}
+	return fmt.Errorf("unknown collision mode: [%s]", mode)
+}
+
+var (
+	errInvalidFileName = errors.New("invalid file name")
+	errInvalidLabel    = errors.New("invalid label")
+)
+
+// sanitizePathSegment replaces characters which are unsafe for file name with '_',
+// and removes leading dots and surrounding spaces to avoid hidden files and '..'.
+// (e.g.) '../foo/bar?.jpg' => '_foo_bar_.jpg'
+func sanitizePathSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, r == 0x7f:
+			return '_'
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimLeft(strings.TrimSpace(s), ".")
+	return strings.TrimRight(s, ". ")
+}
+
+// sanitizeFileName returns sanitized file name, and returns error for empty name.
+func sanitizeFileName(name string) (string, error) {
+	s := sanitizePathSegment(name)
+	if s == "" {
+		return "", errInvalidFileName
+	}
+	return s, nil
+}
+
+// sanitizeLabel returns sanitized label, and '/' in the label is kept for nested dirs.
+// Each dir name is sanitized, and the label is rejected when a dir name is empty after sanitizing. (e.g. '..')
+// Empty label is allowed for files without label.
+// (e.g.) 'animal/cat?' => 'animal/cat_'
+func sanitizeLabel(label string) (string, error) {
+	if strings.TrimSpace(label) == "" {
+		return "", nil
+	}
+
+	segments := strings.Split(strings.Trim(label, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = sanitizePathSegment(seg)
+		if segments[i] == "" {
+			return "", fmt.Errorf("%w: [%s]", errInvalidLabel, label)
+		}
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// sanitizeLabels returns sanitized labels, and returns error when any of them is invalid.
+// Labels of the same dir after sanitizing are removed. (e.g. 'cat?' and 'cat*' => 'cat_')
+func sanitizeLabels(labels []string) ([]string, error) {
+	result := make([]string, 0, len(labels))
+	seen := make(map[string]struct{}, len(labels))
+	for _, label := range labels {
+		s, err := sanitizeLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// placeholders for --name-template.
+const (
+	placeholderHash  = "hash"
+	placeholderHash8 = "hash8"
+	placeholderExt   = "ext"
+)
+
+var placeholderRe = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// extPlaceholder is kept in the file name when the URL does not have extension,
+// and it is replaced with the extension from the content type after download.
+const extPlaceholder = "{" + placeholderExt + "}"
+
+// fileNameTemplate builds file name from the row. (e.g. '{id}_{hash8}{ext}')
+// {hash} and {hash8} are SHA1 hash of the URL, {ext} is file extension of the URL,
+// and others are column values of the row.
+type fileNameTemplate struct {
+	template string
+	lowerExt bool
+}
+
+func newFileNameTemplate(template string, lowerExt bool) fileNameTemplate {
+	return fileNameTemplate{
+		template: template,
+		lowerExt: lowerExt,
+	}
+}
+
+// columns returns column names used in the template.
+func (t fileNameTemplate) columns() []string {
+	var cols []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(t.template, -1) {
+		switch m[1] {
+		case placeholderHash, placeholderHash8, placeholderExt:
+			continue
+		}
+		cols = append(cols, m[1])
+	}
+	return cols
+}
+
+// execute returns sanitized file name, and returns error when a column value is empty.
+func (t fileNameTemplate) execute(line map[string]string, uri string) (string, error) {
+	hasEmpty := false
+	name := placeholderRe.ReplaceAllStringFunc(t.template, func(s string) string {
+		key := s[1 : len(s)-1]
+		switch key {
+		case placeholderHash:
+			return getURLHash(uri)
+		case placeholderHash8:
+			return getURLHash(uri)[:8]
+		case placeholderExt:
+			ext := getURLExt(uri)
+			switch {
+			case ext == "":
+				return extPlaceholder
+			case t.lowerExt:
+				return strings.ToLower(ext)
+			}
+			return ext
+		}
+		v := strings.ReplaceAll(sanitizePathSegment(line[key]), extPlaceholder, "")
+		if v == "" {
+			hasEmpty = true
+		}
+		return v
+	})
+	if hasEmpty {
+		return "", errInvalidFileName
+	}
+	return sanitizeFileName(name)
+}
+
+// getURLHash returns hex encoded SHA1 hash of the URL.
+func getURLHash(uri string) string {
+	h := sha1.Sum([]byte(uri)) //nolint:gosec
+	return hex.EncodeToString(h[:])
+}
+
+// getURLExt returns file extension of the URL path.
+func getURLExt(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return filepath.Ext(u.Path)
+}
+
+// addHashSuffix adds hash of the URL to the file name to avoid collision.
+// (e.g.) '1.jpg' => '1_0a1b2c3d.jpg'
+func addHashSuffix(name, uri string) string {
+	suffix := "_" + getURLHash(uri)[:8]
+	if hasExtPlaceholder(name) {
+		return strings.Replace(name, extPlaceholder, suffix+extPlaceholder, 1)
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + suffix + ext
+}
+
+func hasExtPlaceholder(name string) bool {
+	return strings.Contains(name, extPlaceholder)
+}
+
+// withExt replaces the extension placeholder in the file name.
+func withExt(name, ext string) string {
+	return strings.Replace(name, extPlaceholder, ext, 1)
+}
+
+// findFileWithExt finds the file in the dir, and tries known extensions for the extension placeholder.
+func findFileWithExt(dir, name string) (string, bool) {
+	if !hasExtPlaceholder(name) {
+		return name, isFileExist(filepath.Join(dir, name))
+	}
+
+	for _, ext := range append(getKnownExts(), "") {
+		s := withExt(name, ext)
+		if isFileExist(filepath.Join(dir, s)) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// renameNoReplace renames the file, and returns error when the new path already exists.
+// Hard link is used not to overwrite the file created at the same time, and it falls back to rename.
+func renameNoReplace(oldPath, newPath string) error {
+	err := os.Link(oldPath, newPath)
+	switch {
+	case err == nil:
+		return os.Remove(oldPath)
+	case os.IsExist(err):
+		return err
+	}
+
+	if _, err := os.Lstat(newPath); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrExist}
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// fileNameMap keeps owner URL of the file path to detect collision between rows.
+type fileNameMap struct {
+	dataMu sync.Mutex
+	data   map[string]string
+}
+
+func newFileNameMap() fileNameMap {
+	return fileNameMap{
+		data: make(map[string]string),
+	}
+}
+
+// claim sets the URL as owner of the file path, and returns false when other URL owns it.
+// The file path with the extension placeholder collides with the paths of known extensions,
+// as the extension is decided from the content type after download. (e.g. 'cat/1{ext}' and 'cat/1.jpg')
+func (m *fileNameMap) claim(filePath, uri string) (owner string, ok bool) {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	for _, p := range getCollisionPaths(filePath) {
+		if owner, exists := m.data[p]; exists && owner != uri {
+			return owner, false
+		}
+	}
+	m.data[filePath] = uri
+	return uri, true
+}
+
+// getCollisionPaths returns the file path and the other paths which can be the same file after download.
+func getCollisionPaths(filePath string) []string {
+	paths := []string{filePath}
+	if hasExtPlaceholder(filePath) {
+		for _, ext := range append(getKnownExts(), "") {
+			paths = append(paths, withExt(filePath, ext))
+		}
+		return paths
+	}
+
+	ext := filepath.Ext(filePath)
+	if ext == "" || isKnownExt(ext) {
+		paths = append(paths, strings.TrimSuffix(filePath, ext)+extPlaceholder)
+	}
+	return paths
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		isErr    bool
+	}{
+		{"1.jpg", "1.jpg", false},
+		{"../foo/bar?.jpg", "_foo_bar_.jpg", false},
+		{`a\b:c*d"e<f>g|h.jpg`, "a_b_c_d_e_f_g_h.jpg", false},
+		{" .hidden.jpg ", "hidden.jpg", false},
+		{"tab\tname.jpg", "tab_name.jpg", false},
+		{"name. ", "name", false},
+		{"..", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sanitizeFileName(tt.name)
+			if tt.isErr {
+				if !errors.Is(err, errInvalidFileName) {
+					t.Errorf("expected invalid file name error, but got [%s]", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizeLabel(t *testing.T) {
+	tests := []struct {
+		label    string
+		expected string
+		isErr    bool
+	}{
+		{"cat", "cat", false},
+		{"", "", false},
+		{" ", "", false},
+		{"animal/cat", "animal/cat", false},
+		{"/animal/cat/", "animal/cat", false},
+		{"animal/cat?", "animal/cat_", false},
+		{`animal\cat`, "animal_cat", false},
+		{".hidden/cat", "hidden/cat", false},
+		{"..", "", true},
+		{"../cat", "", true},
+		{"animal/../cat", "", true},
+		{"animal//cat", "", true},
+		{"/", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			result, err := sanitizeLabel(tt.label)
+			if tt.isErr {
+				if !errors.Is(err, errInvalidLabel) {
+					t.Errorf("expected invalid label error, but got [%s]", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizeLabels(t *testing.T) {
+	tests := []struct {
+		labels   []string
+		expected []string
+		isErr    bool
+	}{
+		{[]string{"cat"}, []string{"cat"}, false},
+		{[]string{"cat", "indoor"}, []string{"cat", "indoor"}, false},
+		{[]string{"cat", "cat/"}, []string{"cat"}, false},
+		{[]string{"cat?", "indoor", "cat*"}, []string{"cat_", "indoor"}, false},
+		{[]string{"animal/cat", "/animal/cat/"}, []string{"animal/cat"}, false},
+		{[]string{""}, []string{""}, false},
+		{[]string{"cat", ".."}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.labels, "|"), func(t *testing.T) {
+			result, err := sanitizeLabels(tt.labels)
+			if tt.isErr {
+				if !errors.Is(err, errInvalidLabel) {
+					t.Errorf("expected invalid label error, but got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, but got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFileNameTemplate(t *testing.T) {
+	line := map[string]string{
+		"id":    "1",
+		"name":  "../foo",
+		"empty": "",
+	}
+	tests := []struct {
+		template string
+		uri      string
+		lowerExt bool
+		expected string
+		isErr    bool
+	}{
+		{"{id}{ext}", "http://example.com/a.jpg", false, "1.jpg", false},
+		{"{id}{ext}", "http://example.com/a.JPG", true, "1.jpg", false},
+		{"{id}{ext}", "http://example.com/img?id=1", false, "1{ext}", false},
+		{"{id}_{hash8}{ext}", "http://example.com/a.jpg", false, "1_" + getURLHash("http://example.com/a.jpg")[:8] + ".jpg", false},
+		{"{name}{ext}", "http://example.com/a.jpg", false, "_foo.jpg", false},
+		{"{empty}{ext}", "http://example.com/a.jpg", false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template+"_"+tt.uri, func(t *testing.T) {
+			result, err := newFileNameTemplate(tt.template, tt.lowerExt).execute(line, tt.uri)
+			if tt.isErr {
+				if err == nil {
+					t.Errorf("expected error, but got [%s]", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestAddHashSuffix(t *testing.T) {
+	uri := "http://example.com/a.jpg"
+	hash := getURLHash(uri)[:8]
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"1.jpg", "1_" + hash + ".jpg"},
+		{"1{ext}", "1_" + hash + "{ext}"},
+		{"1", "1_" + hash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := addHashSuffix(tt.name, uri)
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFileNameMapClaim(t *testing.T) {
+	m := newFileNameMap()
+	tests := []struct {
+		filePath string
+		uri      string
+		owner    string
+		ok       bool
+	}{
+		{"cat/1.jpg", "http://example.com/a.jpg", "http://example.com/a.jpg", true},
+		// the same URL can claim the same path again.
+		{"cat/1.jpg", "http://example.com/a.jpg", "http://example.com/a.jpg", true},
+		// the first URL keeps the path.
+		{"cat/1.jpg", "http://example.com/b.jpg", "http://example.com/a.jpg", false},
+		{"dog/1.jpg", "http://example.com/b.jpg", "http://example.com/b.jpg", true},
+		{"cat/1.jpg", "http://example.com/c.jpg", "http://example.com/a.jpg", false},
+		// the extension placeholder collides with known extensions and no extension.
+		{"cat/1{ext}", "http://example.com/img?id=4", "http://example.com/a.jpg", false},
+		{"cat/2{ext}", "http://example.com/img?id=5", "http://example.com/img?id=5", true},
+		{"cat/2.png", "http://example.com/b.png", "http://example.com/img?id=5", false},
+		{"cat/2", "http://example.com/b", "http://example.com/img?id=5", false},
+		{"cat/2.png", "http://example.com/img?id=5", "http://example.com/img?id=5", true},
+		// unknown extension is not the result of the placeholder.
+		{"cat/2.tar.gz", "http://example.com/b.tar.gz", "http://example.com/b.tar.gz", true},
+		{"cat/2_b{ext}", "http://example.com/img?id=6", "http://example.com/img?id=6", true},
+	}
+
+	for _, tt := range tests {
+		owner, ok := m.claim(tt.filePath, tt.uri)
+		if ok != tt.ok || owner != tt.owner {
+			t.Errorf("claim(%s, %s): expected [%s, %t], but got [%s, %t]", tt.filePath, tt.uri, tt.owner, tt.ok, owner, ok)
+		}
+	}
+}
+
+func TestRenameNoReplace(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "1")
+	dst := filepath.Join(dir, "1.jpg")
+	other := filepath.Join(dir, "2")
+	for _, f := range []string{src, other} {
+		if err := os.WriteFile(f, []byte(f), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := renameNoReplace(src, dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isFileExist(src) {
+		t.Errorf("expected the old path is removed: [%s]", src)
+	}
+
+	// the existing file is not overwritten.
+	if err := renameNoReplace(other, dst); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected exist error, but got [%v]", err)
+	}
+	byt, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(byt) != src {
+		t.Errorf("expected [%s], but got [%s]", src, byt)
+	}
+	if !isFileExist(other) {
+		t.Errorf("expected the old path is kept on error: [%s]", other)
+	}
+}
+
+func TestFindFileWithExt(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1.jpg", "2", "3.JPG", "4.png", "4.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		isExist  bool
+	}{
+		{"1.jpg", "1.jpg", true},
+		{"1.png", "1.png", false},
+		{"1{ext}", "1.jpg", true},
+		{"2{ext}", "2", true},
+		{"2", "2", true},
+		{"3.JPG", "3.JPG", true},
+		// the first one of the sorted extensions is used for the collision.
+		{"4{ext}", "4.jpg", true},
+		{"5{ext}", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, isExist := findFileWithExt(dir, tt.name)
+			if isExist != tt.isExist {
+				t.Errorf("expected [%t], but got [%t]", tt.isExist, isExist)
+			}
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"path/filepath"
 	"strings"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
 )
 
 // checking file extension.
@@ -37,6 +39,11 @@ func (f *fileType) setIncludeAll(b bool) {
 }
 
 func (f fileType) isTarget(path string) bool {
+	// temporary files left after crash.
+	if provider.IsTempFile(path) {
+		return false
+	}
+
 	if f.includeAll {
 		if !f.excludeDot {
 			return true
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestFileTypeIsTarget(t *testing.T) {
+	types := newFileType([]string{"jpg", "tmp"})
+	all := newFileType([]string{"jpg"})
+	all.setIncludeAll(true)
+
+	tests := []struct {
+		path        string
+		expected    bool
+		expectedAll bool
+	}{
+		{"cat/1.jpg", true, true},
+		{"cat/1.JPG", true, true},
+		{"cat/1.png", false, true},
+		{"cat/.1.jpg", true, false},
+		{"cat/.1.jpg.123456.tmp", false, false},
+		{"cat/.1.jpg.download.tmp", false, false},
+	}
+	for _, tt := range tests {
+		if result := types.isTarget(tt.path); result != tt.expected {
+			t.Errorf("path=[%s]: expected %v, but got %v", tt.path, tt.expected, result)
+		}
+		if result := all.isTarget(tt.path); result != tt.expectedAll {
+			t.Errorf("path=[%s] with --all: expected %v, but got %v", tt.path, tt.expectedAll, result)
+		}
+	}
+}
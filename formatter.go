@@ -1,47 +1,410 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func createListFormat(name string) (formatter, error) {
+// formatOption is options for list formats.
+type formatOption struct {
+	// label attribute name and job name of SageMaker Ground Truth.
+	labelAttribute string
+	jobName        string
+	// label => class id
+	classMap map[string]int
+}
+
+func createListFormat(name string, opt formatOption) (recordWriter, error) {
 	name = strings.ToLower(name)
 	switch name {
 	case "sagemaker":
-		return &sagemakerFormatter{}, nil
+		return newLineWriter(newSagemakerFormatter(opt)), nil
 	case "csv":
-		return &csvFormatter{}, nil
+		return newLineWriter(&csvFormatter{}), nil
+	case "automl-classification":
+		return newLineWriter(&automlClassificationFormatter{}), nil
+	case "vertex-classification":
+		return newLineWriter(&vertexClassificationFormatter{}), nil
+	default:
+		return nil, fmt.Errorf("Unknown Format: [%s]", name)
+	}
+}
+
+func createVottFormat(name string) (recordWriter, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case "automl-detection":
+		return newLineWriter(&automlObjectDetectionFormatter{}), nil
+	case "vertex-detection":
+		return newLineWriter(&vertexDetectionFormatter{}), nil
+	case "vertex-classification":
+		return newLineWriter(&vertexClassificationFormatter{}), nil
+	case "coco":
+		return &cocoWriter{}, nil
+	case "voc":
+		return newVOCWriter(), nil
 	default:
 		return nil, fmt.Errorf("Unknown Format: [%s]", name)
 	}
 }
 
+// formatter formats a record into lines of the list.
 type formatter interface {
-	format(path, label string) string
+	format(rec labelRecord) ([]string, error)
+}
+
+// labelRecord is an image of the list with the labels and annotations.
+type labelRecord struct {
+	// path is URL path of the image. (e.g. 'gs://bucket/cat/1.jpg')
+	path string
+	// name is relative path of the image. (e.g. 'cat/1.jpg')
+	name   string
+	labels []string
+	boxes  []boundingBox
+	// split is ML_USE of the image. (e.g. 'TRAIN', 'UNASSIGNED')
+	split    string
+	metadata imageMetadata
+}
+
+// imageMetadata is metadata of the image. Size is zero when it's unknown. (e.g. list command)
+type imageMetadata struct {
+	width  int64
+	height int64
+}
+
+// boundingBox is a labeled box with normalized vertices (0.0 - 1.0).
+// polygon has the vertices of polygon region, and it's empty for rectangle region.
+type boundingBox struct {
+	label   string
+	xMin    float64
+	yMin    float64
+	xMax    float64
+	yMax    float64
+	polygon []point
+}
+
+// point is a normalized vertex (0.0 - 1.0).
+type point struct {
+	x float64
+	y float64
 }
 
 type csvFormatter struct{}
 
-func (csvFormatter) format(path, label string) string {
-	return fmt.Sprintf("%s,%s", path, label)
+func (csvFormatter) format(rec labelRecord) ([]string, error) {
+	return []string{fmt.Sprintf("%s,%s", rec.path, strings.Join(rec.labels, ","))}, nil
 }
 
-type sagemakerFormatter struct{}
+// time format of creation-date in SageMaker Ground Truth manifest.
+const sagemakerTimeFormat = "2006-01-02T15:04:05.000000"
 
-func (sagemakerFormatter) format(path, label string) string {
-	return fmt.Sprintf(`{"source-ref": "%s"}`, path)
+// sagemakerFormatter creates augmented manifest of SageMaker Ground Truth for image classification.
+// Multiple labels (comma separated) are formatted as multi-label classification,
+// and files without label have 'source-ref' only.
+type sagemakerFormatter struct {
+	labelAttribute string
+	jobName        string
+	creationDate   string
+	classMap       map[string]int
+	nextClassID    int
 }
 
-type automlObjectDetectionFormatter struct {
-	pathPrefix string
-	defaultSet string
+func newSagemakerFormatter(opt formatOption) *sagemakerFormatter {
+	attr := opt.labelAttribute
+	if attr == "" {
+		attr = "class"
+	}
+	jobName := opt.jobName
+	if jobName == "" {
+		jobName = "labeling-job/" + attr
+	}
+
+	classMap := make(map[string]int, len(opt.classMap))
+	nextClassID := 0
+	for label, id := range opt.classMap {
+		classMap[label] = id
+		if id >= nextClassID {
+			nextClassID = id + 1
+		}
+	}
+	return &sagemakerFormatter{
+		labelAttribute: attr,
+		jobName:        jobName,
+		creationDate:   time.Now().UTC().Format(sagemakerTimeFormat),
+		classMap:       classMap,
+		nextClassID:    nextClassID,
+	}
+}
+
+type sagemakerMetadata struct {
+	ClassName      string  `json:"class-name"`
+	Confidence     float64 `json:"confidence"`
+	Type           string  `json:"type"`
+	JobName        string  `json:"job-name"`
+	HumanAnnotated string  `json:"human-annotated"`
+	CreationDate   string  `json:"creation-date"`
+}
+
+type sagemakerMultiLabelMetadata struct {
+	ClassMap       map[string]string  `json:"class-map"`
+	ConfidenceMap  map[string]float64 `json:"confidence-map"`
+	Type           string             `json:"type"`
+	JobName        string             `json:"job-name"`
+	HumanAnnotated string             `json:"human-annotated"`
+	CreationDate   string             `json:"creation-date"`
+}
+
+func (f *sagemakerFormatter) format(rec labelRecord) ([]string, error) {
+	labels := rec.labels
+
+	// keep the order of the keys for readability.
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"source-ref":`)
+	if err := writeJSON(buf, rec.path); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch len(labels) {
+	case 0:
+	case 1:
+		err = f.writeAttribute(buf, f.getClassID(labels[0]), sagemakerMetadata{
+			ClassName:      labels[0],
+			Confidence:     1,
+			Type:           "groundtruth/image-classification",
+			JobName:        f.jobName,
+			HumanAnnotated: "yes",
+			CreationDate:   f.creationDate,
+		})
+	default:
+		ids := make([]int, 0, len(labels))
+		classMap := make(map[string]string, len(labels))
+		confidenceMap := make(map[string]float64, len(labels))
+		for _, l := range labels {
+			id := f.getClassID(l)
+			ids = append(ids, id)
+			classMap[fmt.Sprint(id)] = l
+			confidenceMap[fmt.Sprint(id)] = 1
+		}
+		sort.Ints(ids)
+		err = f.writeAttribute(buf, ids, sagemakerMultiLabelMetadata{
+			ClassMap:       classMap,
+			ConfidenceMap:  confidenceMap,
+			Type:           "groundtruth/image-classification-multilabel",
+			JobName:        f.jobName,
+			HumanAnnotated: "yes",
+			CreationDate:   f.creationDate,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString("}")
+	return []string{buf.String()}, nil
+}
+
+func (f *sagemakerFormatter) writeAttribute(buf *bytes.Buffer, value, metadata interface{}) error {
+	buf.WriteString(",")
+	if err := writeJSON(buf, f.labelAttribute); err != nil {
+		return err
+	}
+	buf.WriteString(":")
+	if err := writeJSON(buf, value); err != nil {
+		return err
+	}
+	buf.WriteString(",")
+	if err := writeJSON(buf, f.labelAttribute+"-metadata"); err != nil {
+		return err
+	}
+	buf.WriteString(":")
+	return writeJSON(buf, metadata)
+}
+
+// getClassID returns class id from the class map, and assigns new id for unknown label.
+func (f *sagemakerFormatter) getClassID(label string) int {
+	if id, ok := f.classMap[label]; ok {
+		return id
+	}
+	id := f.nextClassID
+	f.classMap[label] = id
+	f.nextClassID++
+	return id
+}
+
+// writeJSON writes JSON of the value into the buffer.
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	byt, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(byt)
+	return nil
+}
+
+// formatJSONLine returns JSON of the value as a line of JSONL.
+func formatJSONLine(v interface{}) ([]string, error) {
+	byt, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(byt)}, nil
+}
+
+// readClassMap reads class map JSON file. (e.g. '{"cat": 0, "dog": 1}')
+func readClassMap(file string) (map[string]int, error) {
+	byt, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	classMap := make(map[string]int)
+	if err := json.Unmarshal(byt, &classMap); err != nil {
+		return nil, fmt.Errorf("invalid class map: [%s], %w", file, err)
+	}
+	return classMap, nil
+}
+
+// automlClassificationFormatter creates import CSV of Vertex AI (AutoML Vision) for image classification,
+// which has ML_USE column and labels. (e.g. 'TRAIN,gs://bucket/1.jpg,cat,indoor')
+type automlClassificationFormatter struct{}
+
+func (f automlClassificationFormatter) format(rec labelRecord) ([]string, error) {
+	fields := append([]string{getMLUse(rec.split), rec.path}, rec.labels...)
+	return []string{formatCSVLine(fields)}, nil
+}
+
+// formatCSVLine returns a line of CSV with quotes for the fields if needed.
+func formatCSVLine(fields []string) string {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.Write(fields)
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// automlObjectDetectionFormatter creates import CSV of Vertex AI (AutoML Vision) for object detection,
+// which has a line per bounding box. (e.g. 'TRAIN,gs://bucket/1.jpg,cat,0.1,0.1,0.5,0.1,0.5,0.5,0.1,0.5')
+type automlObjectDetectionFormatter struct{}
+
+func (f automlObjectDetectionFormatter) format(rec labelRecord) ([]string, error) {
+	lines := make([]string, 0, len(rec.boxes))
+	for _, b := range rec.boxes {
+		// vertices: [(x1,y1), (x2,y1), (x2,y2), (x1,y2)]
+		lines = append(lines, strings.Join([]string{
+			getMLUse(rec.split),
+			rec.path,
+			b.label,
+			formatFloat(b.xMin), formatFloat(b.yMin),
+			formatFloat(b.xMax), formatFloat(b.yMin),
+			formatFloat(b.xMax), formatFloat(b.yMax),
+			formatFloat(b.xMin), formatFloat(b.yMax),
+		}, ","))
+	}
+	return lines, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// getMLUse returns ML_USE of the split, and returns UNASSIGNED for empty split.
+func getMLUse(split string) string {
+	if split == "" {
+		return splitUnassigned
+	}
+	return split
+}
+
+// ML_USE values of dataItemResourceLabels in Vertex AI JSONL.
+var vertexMLUse = map[string]string{
+	splitTrain:      "training",
+	splitValidation: "validation",
+	splitTest:       "test",
+}
+
+const vertexMLUseKey = "aiplatform.googleapis.com/ml_use"
+
+type vertexAnnotation struct {
+	DisplayName string `json:"displayName"`
+}
+
+type vertexBoundingBoxAnnotation struct {
+	DisplayName string  `json:"displayName"`
+	XMin        float64 `json:"xMin"`
+	YMin        float64 `json:"yMin"`
+	XMax        float64 `json:"xMax"`
+	YMax        float64 `json:"yMax"`
+}
+
+// vertexClassificationItem is a line of Vertex AI JSONL for single-label/multi-label classification.
+type vertexClassificationItem struct {
+	ImageGcsURI               string             `json:"imageGcsUri"`
+	ClassificationAnnotation  *vertexAnnotation  `json:"classificationAnnotation,omitempty"`
+	ClassificationAnnotations []vertexAnnotation `json:"classificationAnnotations,omitempty"`
+	DataItemResourceLabels    map[string]string  `json:"dataItemResourceLabels,omitempty"`
+}
+
+// vertexDetectionItem is a line of Vertex AI JSONL for object detection.
+type vertexDetectionItem struct {
+	ImageGcsURI            string                        `json:"imageGcsUri"`
+	BoundingBoxAnnotations []vertexBoundingBoxAnnotation `json:"boundingBoxAnnotations,omitempty"`
+	DataItemResourceLabels map[string]string             `json:"dataItemResourceLabels,omitempty"`
+}
+
+// vertexClassificationFormatter creates import JSONL of Vertex AI for image classification.
+// Multiple labels are formatted as multi-label classification.
+type vertexClassificationFormatter struct{}
+
+func (f vertexClassificationFormatter) format(rec labelRecord) ([]string, error) {
+	v := vertexClassificationItem{
+		ImageGcsURI:            rec.path,
+		DataItemResourceLabels: getVertexResourceLabels(rec.split),
+	}
+	switch len(rec.labels) {
+	case 0:
+	case 1:
+		v.ClassificationAnnotation = &vertexAnnotation{DisplayName: rec.labels[0]}
+	default:
+		for _, l := range rec.labels {
+			v.ClassificationAnnotations = append(v.ClassificationAnnotations, vertexAnnotation{DisplayName: l})
+		}
+	}
+	return formatJSONLine(v)
+}
+
+// vertexDetectionFormatter creates import JSONL of Vertex AI for object detection,
+// which has a line per image with the bounding boxes.
+type vertexDetectionFormatter struct{}
+
+func (f vertexDetectionFormatter) format(rec labelRecord) ([]string, error) {
+	v := vertexDetectionItem{
+		ImageGcsURI:            rec.path,
+		DataItemResourceLabels: getVertexResourceLabels(rec.split),
+	}
+	for _, b := range rec.boxes {
+		v.BoundingBoxAnnotations = append(v.BoundingBoxAnnotations, vertexBoundingBoxAnnotation{
+			DisplayName: b.label,
+			XMin:        b.xMin,
+			YMin:        b.yMin,
+			XMax:        b.xMax,
+			YMax:        b.yMax,
+		})
+	}
+	return formatJSONLine(v)
 }
 
-func (f automlObjectDetectionFormatter) format(path, label string) string {
-	set := f.defaultSet
-	if set == "" {
-		set = "UNASSIGNED"
+// getVertexResourceLabels returns dataItemResourceLabels for ML_USE, and returns nil for UNASSIGNED.
+func getVertexResourceLabels(split string) map[string]string {
+	mlUse, ok := vertexMLUse[split]
+	if !ok {
+		return nil
 	}
-	return fmt.Sprintf("%s,%s,%s", set, f.pathPrefix+path, label)
+	return map[string]string{vertexMLUseKey: mlUse}
 }
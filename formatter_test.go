@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateListFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		isErr bool
+	}{
+		{"csv", false},
+		{"sagemaker", false},
+		{"automl-classification", false},
+		{"Vertex-Classification", false},
+		// list has no bounding boxes and image size.
+		{"vertex-detection", true},
+		{"coco", true},
+		{"voc", true},
+		{"automl-detection", true},
+		{"unknown", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createListFormat(tt.name, formatOption{})
+			if tt.isErr != (err != nil) {
+				t.Errorf("expected error=[%t], but got [%v]", tt.isErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateVottFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		isErr bool
+	}{
+		{"automl-detection", false},
+		{"vertex-detection", false},
+		{"vertex-classification", false},
+		{"COCO", false},
+		{"voc", false},
+		{"csv", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createVottFormat(tt.name)
+			if tt.isErr != (err != nil) {
+				t.Errorf("expected error=[%t], but got [%v]", tt.isErr, err)
+			}
+		})
+	}
+}
+
+// decodeTestJSON decodes a JSON line for the formatter tests.
+func decodeTestJSON(t *testing.T, line string) map[string]interface{} {
+	t.Helper()
+
+	v := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(line), &v); err != nil {
+		t.Fatalf("invalid JSON: [%s], %v", line, err)
+	}
+	return v
+}
+
+func TestSagemakerFormatter(t *testing.T) {
+	f := newSagemakerFormatter(formatOption{
+		labelAttribute: "animal",
+		classMap:       map[string]int{"dog": 3},
+	})
+	f.creationDate = "2021-01-02T03:04:05.000000"
+
+	tests := []struct {
+		name     string
+		rec      labelRecord
+		expected map[string]interface{}
+	}{
+		{
+			name: "single label",
+			rec:  labelRecord{path: "s3://bucket/cat/1.jpg", labels: []string{"cat"}},
+			expected: map[string]interface{}{
+				"source-ref": "s3://bucket/cat/1.jpg",
+				"animal":     float64(4),
+				"animal-metadata": map[string]interface{}{
+					"class-name":      "cat",
+					"confidence":      float64(1),
+					"type":            "groundtruth/image-classification",
+					"job-name":        "labeling-job/animal",
+					"human-annotated": "yes",
+					"creation-date":   "2021-01-02T03:04:05.000000",
+				},
+			},
+		},
+		{
+			name: "class id from the class map",
+			rec:  labelRecord{path: "s3://bucket/dog/2.jpg", labels: []string{"dog"}},
+			expected: map[string]interface{}{
+				"source-ref": "s3://bucket/dog/2.jpg",
+				"animal":     float64(3),
+				"animal-metadata": map[string]interface{}{
+					"class-name":      "dog",
+					"confidence":      float64(1),
+					"type":            "groundtruth/image-classification",
+					"job-name":        "labeling-job/animal",
+					"human-annotated": "yes",
+					"creation-date":   "2021-01-02T03:04:05.000000",
+				},
+			},
+		},
+		{
+			name: "multi-label",
+			rec:  labelRecord{path: "s3://bucket/3.jpg", labels: []string{"indoor", "dog", "cat"}},
+			expected: map[string]interface{}{
+				"source-ref": "s3://bucket/3.jpg",
+				"animal":     []interface{}{float64(3), float64(4), float64(5)},
+				"animal-metadata": map[string]interface{}{
+					"class-map":       map[string]interface{}{"3": "dog", "4": "cat", "5": "indoor"},
+					"confidence-map":  map[string]interface{}{"3": float64(1), "4": float64(1), "5": float64(1)},
+					"type":            "groundtruth/image-classification-multilabel",
+					"job-name":        "labeling-job/animal",
+					"human-annotated": "yes",
+					"creation-date":   "2021-01-02T03:04:05.000000",
+				},
+			},
+		},
+		{
+			name: "no label",
+			rec:  labelRecord{path: "s3://bucket/\"4\".jpg"},
+			expected: map[string]interface{}{
+				"source-ref": `s3://bucket/"4".jpg`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := f.format(tt.rec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(lines) != 1 {
+				t.Fatalf("expected 1 line, but got %v", lines)
+			}
+			if !strings.HasPrefix(lines[0], `{"source-ref":`) {
+				t.Errorf("expected source-ref on the first, but got [%s]", lines[0])
+			}
+			if v := decodeTestJSON(t, lines[0]); !reflect.DeepEqual(v, tt.expected) {
+				t.Errorf("expected %v, but got %v", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestSagemakerFormatterDefault(t *testing.T) {
+	f := newSagemakerFormatter(formatOption{jobName: "my-job"})
+	lines, err := f.format(labelRecord{path: "s3://bucket/cat/1.jpg", labels: []string{"cat"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, but got %v", lines)
+	}
+
+	v := decodeTestJSON(t, lines[0])
+	if id, ok := v["class"]; !ok || id != float64(0) {
+		t.Errorf("expected class id [0], but got [%v]", id)
+	}
+	metadata, ok := v["class-metadata"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected class-metadata, but got %v", v)
+	}
+	if metadata["job-name"] != "my-job" {
+		t.Errorf("expected [my-job], but got [%v]", metadata["job-name"])
+	}
+	if metadata["class-name"] != "cat" {
+		t.Errorf("expected [cat], but got [%v]", metadata["class-name"])
+	}
+}
+
+func TestAutomlClassificationFormatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		rec      labelRecord
+		expected string
+	}{
+		{
+			name:     "single label",
+			rec:      labelRecord{path: "gs://bucket/cat/1.jpg", labels: []string{"cat"}, split: splitTrain},
+			expected: "TRAIN,gs://bucket/cat/1.jpg,cat",
+		},
+		{
+			name:     "multiple labels",
+			rec:      labelRecord{path: "gs://bucket/dog/2.jpg", labels: []string{"dog", "indoor"}, split: splitValidation},
+			expected: "VALIDATION,gs://bucket/dog/2.jpg,dog,indoor",
+		},
+		{
+			name:     "unassigned split",
+			rec:      labelRecord{path: "gs://bucket/cat/3.jpg", labels: []string{"cat"}, split: splitUnassigned},
+			expected: "UNASSIGNED,gs://bucket/cat/3.jpg,cat",
+		},
+		{
+			name:     "empty split",
+			rec:      labelRecord{path: "gs://bucket/4.jpg", split: ""},
+			expected: "UNASSIGNED,gs://bucket/4.jpg",
+		},
+		{
+			name:     "quoted label",
+			rec:      labelRecord{path: "gs://bucket/5.jpg", labels: []string{"cat,dog", `"bird"`}, split: splitTest},
+			expected: `TEST,gs://bucket/5.jpg,"cat,dog","""bird"""`,
+		},
+	}
+
+	f := automlClassificationFormatter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := f.format(tt.rec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(lines, []string{tt.expected}) {
+				t.Errorf("expected [%s], but got %v", tt.expected, lines)
+			}
+		})
+	}
+}
+
+func TestAutomlClassificationOutput(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "list.csv")
+	records := []labelRecord{
+		{path: "gs://bucket/cat/1.jpg", labels: []string{"cat"}, split: splitTrain},
+		{path: "gs://bucket/dog/2.jpg", labels: []string{"dog", "indoor"}, split: splitTest},
+		{path: "gs://bucket/3.jpg", labels: []string{"cat"}, split: splitUnassigned},
+	}
+	w, err := createListFormat("automl-classification", formatOption{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := writeRecords(w, output, records, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"TRAIN,gs://bucket/cat/1.jpg,cat",
+		"TEST,gs://bucket/dog/2.jpg,dog,indoor",
+		"UNASSIGNED,gs://bucket/3.jpg,cat",
+	}, "\n")
+	if result := readTestFile(t, output); result != expected {
+		t.Errorf("expected [%s], but got [%s]", expected, result)
+	}
+}
+
+func TestVertexClassificationFormatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		rec      labelRecord
+		expected string
+	}{
+		{
+			name:     "single label",
+			rec:      labelRecord{path: "gs://bucket/cat/1.jpg", labels: []string{"cat"}, split: splitTrain},
+			expected: `{"imageGcsUri":"gs://bucket/cat/1.jpg","classificationAnnotation":{"displayName":"cat"},"dataItemResourceLabels":{"aiplatform.googleapis.com/ml_use":"training"}}`,
+		},
+		{
+			name:     "multi-label",
+			rec:      labelRecord{path: "gs://bucket/dog/2.jpg", labels: []string{"dog", "indoor"}, split: splitValidation},
+			expected: `{"imageGcsUri":"gs://bucket/dog/2.jpg","classificationAnnotations":[{"displayName":"dog"},{"displayName":"indoor"}],"dataItemResourceLabels":{"aiplatform.googleapis.com/ml_use":"validation"}}`,
+		},
+		{
+			name:     "test split",
+			rec:      labelRecord{path: "gs://bucket/cat/3.jpg", labels: []string{"cat"}, split: splitTest},
+			expected: `{"imageGcsUri":"gs://bucket/cat/3.jpg","classificationAnnotation":{"displayName":"cat"},"dataItemResourceLabels":{"aiplatform.googleapis.com/ml_use":"test"}}`,
+		},
+		{
+			name:     "unassigned split without label",
+			rec:      labelRecord{path: "gs://bucket/4.jpg", split: splitUnassigned},
+			expected: `{"imageGcsUri":"gs://bucket/4.jpg"}`,
+		},
+	}
+
+	f := vertexClassificationFormatter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := f.format(tt.rec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(lines, []string{tt.expected}) {
+				t.Errorf("expected [%s], but got %v", tt.expected, lines)
+			}
+		})
+	}
+}
+
+func TestVertexDetectionFormatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		rec      labelRecord
+		expected string
+	}{
+		{
+			name: "bounding boxes",
+			rec: labelRecord{
+				path:  "gs://bucket/1.jpg",
+				split: splitTrain,
+				boxes: []boundingBox{
+					{label: "cat", xMin: 0.1, yMin: 0.2, xMax: 0.5, yMax: 0.6},
+					{label: "dog", xMin: 0, yMin: 0.25, xMax: 1, yMax: 0.75, polygon: []point{{x: 0, y: 0.25}}},
+				},
+			},
+			expected: `{"imageGcsUri":"gs://bucket/1.jpg","boundingBoxAnnotations":[` +
+				`{"displayName":"cat","xMin":0.1,"yMin":0.2,"xMax":0.5,"yMax":0.6},` +
+				`{"displayName":"dog","xMin":0,"yMin":0.25,"xMax":1,"yMax":0.75}],` +
+				`"dataItemResourceLabels":{"aiplatform.googleapis.com/ml_use":"training"}}`,
+		},
+		{
+			name:     "no box",
+			rec:      labelRecord{path: "gs://bucket/2.jpg", split: splitTest},
+			expected: `{"imageGcsUri":"gs://bucket/2.jpg","dataItemResourceLabels":{"aiplatform.googleapis.com/ml_use":"test"}}`,
+		},
+		{
+			name: "unassigned split",
+			rec: labelRecord{
+				path:  "gs://bucket/3.jpg",
+				split: splitUnassigned,
+				boxes: []boundingBox{{label: "cat", xMin: 0.1, yMin: 0.2, xMax: 0.5, yMax: 0.6}},
+			},
+			expected: `{"imageGcsUri":"gs://bucket/3.jpg","boundingBoxAnnotations":[{"displayName":"cat","xMin":0.1,"yMin":0.2,"xMax":0.5,"yMax":0.6}]}`,
+		},
+	}
+
+	f := vertexDetectionFormatter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := f.format(tt.rec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(lines, []string{tt.expected}) {
+				t.Errorf("expected [%s], but got %v", tt.expected, lines)
+			}
+		})
+	}
+}
+
+func TestFormatterInvalidNumber(t *testing.T) {
+	rec := labelRecord{
+		path:  "gs://bucket/1.jpg",
+		boxes: []boundingBox{{label: "cat", xMin: math.NaN(), yMin: 0, xMax: math.Inf(1), yMax: 1}},
+	}
+
+	// invalid numbers are returned as error without panic.
+	if _, err := (vertexDetectionFormatter{}).format(rec); err == nil {
+		t.Errorf("expected error")
+	}
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "list.jsonl")
+	if err := writeRecords(newLineWriter(&vertexDetectionFormatter{}), output, []labelRecord{rec}, false); err == nil {
+		t.Errorf("expected error")
+	}
+	if files := listTestDir(t, dir); len(files) != 0 {
+		t.Errorf("expected no output files, but got %v", files)
+	}
+}
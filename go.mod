@@ -3,10 +3,13 @@ module github.com/evalphobia/cloud-label-uploader
 go 1.16
 
 require (
-	cloud.google.com/go/storage v1.14.0 // indirect
+	cloud.google.com/go/storage v1.14.0
+	github.com/Azure/azure-storage-blob-go v0.14.0
+	github.com/aws/aws-sdk-go v1.30.19
 	github.com/evalphobia/aws-sdk-go-wrapper v1.16.4
 	github.com/evalphobia/google-api-go-wrapper v0.8.4
 	github.com/mkideal/cli v0.2.5
+	github.com/xitongsys/parquet-go v1.6.2
 	golang.org/x/oauth2 v0.0.0-20210402161424-2e8d93401602 // indirect
-	google.golang.org/api v0.43.0 // indirect
+	google.golang.org/api v0.43.0
 )
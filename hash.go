@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// fileChecksum has hex encoded checksums of the file.
+type fileChecksum struct {
+	Size   int64
+	MD5    string
+	CRC32C string
+}
+
+// getFileChecksum calculates MD5 and CRC32C of the file.
+func getFileChecksum(path string) (fileChecksum, error) {
+	fp, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return fileChecksum{}, err
+	}
+	defer fp.Close() //nolint
+
+	hashMD5 := md5.New() //nolint:gosec
+	hashCRC := crc32.New(crc32cTable)
+	size, err := io.Copy(io.MultiWriter(hashMD5, hashCRC), fp)
+	if err != nil {
+		return fileChecksum{}, err
+	}
+	return fileChecksum{
+		Size:   size,
+		MD5:    hex.EncodeToString(hashMD5.Sum(nil)),
+		CRC32C: fmt.Sprintf("%08x", hashCRC.Sum32()),
+	}, nil
+}
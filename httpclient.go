@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTPOption is command line options for HTTP client.
+type HTTPOption struct {
+	Timeout         duration `cli:"timeout" usage:"timeout for a request including reading body, 0 means no timeout --timeout=10m" dft:"0s"`
+	ResponseTimeout duration `cli:"response-timeout" usage:"timeout to wait for response header, 0 means no timeout --response-timeout=60s" dft:"60s"`
+	Headers         []string `cli:"H,header" usage:"custom request header (multiple) --header='User-Agent: my-crawler'"`
+	BasicAuth       string   `cli:"basic-auth" usage:"basic auth credentials --basic-auth='<user>:<password>'"`
+	Bearer          string   `cli:"bearer" usage:"bearer token for Authorization header --bearer='<token>'"`
+	Proxy           string   `cli:"proxy" usage:"proxy URL (default: HTTP_PROXY/HTTPS_PROXY env vars) --proxy='http://127.0.0.1:8080'"`
+	Insecure        bool     `cli:"insecure" usage:"skip verification of server's TLS certificate"`
+	CACert          string   `cli:"ca-cert" usage:"CA certificate file to verify server --ca-cert='/path/to/ca.pem'"`
+	ClientCert      string   `cli:"client-cert" usage:"client certificate file for TLS --client-cert='/path/to/cert.pem'"`
+	ClientKey       string   `cli:"client-key" usage:"client private key file for TLS --client-key='/path/to/key.pem'"`
+}
+
+// httpClient is HTTP client with custom headers and auth.
+type httpClient struct {
+	client *http.Client
+	header http.Header
+}
+
+func newHTTPClient(opt HTTPOption) (*httpClient, error) {
+	header := make(http.Header)
+	for _, h := range opt.Headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid header: [%s]", h)
+		}
+		header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	switch {
+	case opt.BasicAuth != "" && opt.Bearer != "":
+		return nil, fmt.Errorf("--basic-auth and --bearer cannot be used together")
+	case opt.BasicAuth != "":
+		kv := strings.SplitN(opt.BasicAuth, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid basic auth, use '<user>:<password>' format")
+		}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(opt.BasicAuth)))
+	case opt.Bearer != "":
+		header.Set("Authorization", "Bearer "+opt.Bearer)
+	}
+
+	tlsConfig, err := newTLSConfig(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	// connect and TLS handshake timeouts are from the default transport,
+	// and the whole request has no timeout by default not to fail on large files.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.ResponseHeaderTimeout = opt.ResponseTimeout.Duration
+	if opt.Proxy != "" {
+		u, err := url.Parse(opt.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	return &httpClient{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opt.Timeout.Duration,
+		},
+		header: header,
+	}, nil
+}
+
+func newTLSConfig(opt HTTPOption) (*tls.Config, error) {
+	conf := &tls.Config{
+		InsecureSkipVerify: opt.Insecure, //nolint:gosec
+	}
+
+	if opt.CACert != "" {
+		pem, err := ioutil.ReadFile(opt.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid CA certificate: [%s]", opt.CACert)
+		}
+		conf.RootCAs = pool
+	}
+
+	switch {
+	case opt.ClientCert != "" && opt.ClientKey != "":
+		cert, err := tls.LoadX509KeyPair(opt.ClientCert, opt.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	case opt.ClientCert != "" || opt.ClientKey != "":
+		return nil, fmt.Errorf("both of --client-cert and --client-key are required")
+	}
+	return conf, nil
+}
+
+// Get sends GET request with custom headers.
+func (c *httpClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	return c.client.Do(req)
+}
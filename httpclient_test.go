@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientTimeout(t *testing.T) {
+	// server sends header immediately, and sends body slowly.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-header" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("abc"))
+	}))
+	defer ts.Close()
+
+	c, err := newHTTPClient(HTTPOption{
+		ResponseTimeout: duration{50 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// slow body is not limited by the response header timeout.
+	resp, err := c.Get(ts.URL + "/slow-body")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close() //nolint
+
+	if _, err := c.Get(ts.URL + "/slow-header"); err == nil {
+		t.Errorf("expected timeout error")
+	}
+}
+
+func TestNewHTTPClientHeader(t *testing.T) {
+	c, err := newHTTPClient(HTTPOption{
+		Headers:   []string{"User-Agent: my-crawler", "X-Foo:bar"},
+		BasicAuth: "user:pass",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := c.header.Get("User-Agent"); v != "my-crawler" {
+		t.Errorf("unexpected User-Agent: [%s]", v)
+	}
+	if v := c.header.Get("X-Foo"); v != "bar" {
+		t.Errorf("unexpected X-Foo: [%s]", v)
+	}
+	if v := c.header.Get("Authorization"); v != "Basic dXNlcjpwYXNz" {
+		t.Errorf("unexpected Authorization: [%s]", v)
+	}
+
+	if _, err := newHTTPClient(HTTPOption{BasicAuth: "user:pass", Bearer: "token"}); err == nil {
+		t.Errorf("expected error for --basic-auth with --bearer")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// modes for multi-label rows on download.
+const (
+	multiLabelLink = "link"
+	multiLabelCopy = "copy"
+	multiLabelCSV  = "csv"
+)
+
+const defaultLabelDelimiter = "|"
+
+// splitLabels splits label by the delimiter, and removes empty and duplicate labels.
+// (e.g.) 'cat|indoor' => ['cat', 'indoor']
+func splitLabels(label, delimiter string) []string {
+	if delimiter == "" {
+		return []string{label}
+	}
+
+	var labels []string
+	seen := make(map[string]struct{})
+	for _, s := range strings.Split(label, delimiter) {
+		s = strings.TrimSpace(s)
+		if _, ok := seen[s]; ok || s == "" {
+			continue
+		}
+		seen[s] = struct{}{}
+		labels = append(labels, s)
+	}
+	if len(labels) == 0 {
+		return []string{""}
+	}
+	return labels
+}
+
+// linkOrCopyFile creates dst from src by hardlink or copy.
+// Copy is used when hardlink is not supported. (e.g. across filesystems)
+func linkOrCopyFile(src, dst, mode string) error {
+	if mode == multiLabelLink {
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+	}
+
+	fp, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fp.Close() //nolint:errcheck
+	return provider.WriteFile(dst, fp)
+}
+
+// labelFile is a sidecar CSV file of multi-label rows, which has 'path', 'labels' and 'original' columns.
+// path is relative path from the image dir and labels are joined by the delimiter.
+// original is the path of the file which the linked or copied file is made from, and it is empty for the original file.
+type labelFile struct {
+	delimiter string
+
+	dataMu sync.RWMutex
+	data   map[string][]string
+	copies map[string]string
+}
+
+func newLabelFile(delimiter string) *labelFile {
+	if delimiter == "" {
+		delimiter = defaultLabelDelimiter
+	}
+	return &labelFile{
+		delimiter: delimiter,
+		data:      make(map[string][]string),
+		copies:    make(map[string]string),
+	}
+}
+
+// loadLabelFile reads the sidecar CSV file.
+func loadLabelFile(file, delimiter string) (*labelFile, error) {
+	f, err := NewCSVHandler(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	if err := f.checkHeaders("path", "labels"); err != nil {
+		return nil, err
+	}
+
+	l := newLabelFile(delimiter)
+	for {
+		line, err := f.Read()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return l, nil
+		}
+		labels := splitLabels(line["labels"], l.delimiter)
+		if original := line["original"]; original != "" {
+			l.addCopy(line["path"], original, labels)
+			continue
+		}
+		l.add(line["path"], labels)
+	}
+}
+
+func (l *labelFile) add(path string, labels []string) {
+	l.dataMu.Lock()
+	defer l.dataMu.Unlock()
+	l.data[filepath.ToSlash(path)] = labels
+}
+
+// addCopy adds the path of the file linked or copied from the original.
+// The path same as the original is added as the original, not to be dropped from the list.
+func (l *labelFile) addCopy(path, original string, labels []string) {
+	l.dataMu.Lock()
+	defer l.dataMu.Unlock()
+	path = filepath.ToSlash(path)
+	l.data[path] = labels
+	if original = filepath.ToSlash(original); original != path {
+		l.copies[path] = original
+	}
+}
+
+// isCopy returns true when the path is linked or copied from the other file.
+func (l *labelFile) isCopy(path string) bool {
+	l.dataMu.RLock()
+	defer l.dataMu.RUnlock()
+	_, ok := l.copies[filepath.ToSlash(path)]
+	return ok
+}
+
+func (l *labelFile) get(path string) ([]string, bool) {
+	l.dataMu.RLock()
+	defer l.dataMu.RUnlock()
+	labels, ok := l.data[filepath.ToSlash(path)]
+	return labels, ok
+}
+
+// WriteFile writes rows sorted by path into CSV file.
+func (l *labelFile) WriteFile(file string) error {
+	l.dataMu.RLock()
+	defer l.dataMu.RUnlock()
+
+	paths := make([]string, 0, len(l.data))
+	for path := range l.data {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"path", "labels", "original"})
+	for _, path := range paths {
+		_ = w.Write([]string{path, strings.Join(l.data[path], l.delimiter), l.copies[path]})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return provider.WriteFile(file, buf)
+}
+
+func validateMultiLabelMode(mode string) error {
+	switch mode {
+	case multiLabelLink, multiLabelCopy, multiLabelCSV:
+		return nil
+	}
+	return fmt.Errorf("unknown multi-label mode: [%s]", mode)
+}
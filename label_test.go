@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLabelFileCopies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "labels.csv")
+
+	l := newLabelFile("|")
+	l.add("cat/1.jpg", []string{"cat", "indoor"})
+	l.addCopy("indoor/1.jpg", "cat/1.jpg", []string{"cat", "indoor"})
+	l.add("dog/2.jpg", []string{"dog"})
+	// the original is not recorded as the copy of itself.
+	l.addCopy("bird/3.jpg", "bird/3.jpg", []string{"bird"})
+	if err := l.WriteFile(file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := loadLabelFile(file, "|")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, path := range []string{"cat/1.jpg", "indoor/1.jpg"} {
+		labels, ok := loaded.get(path)
+		if !ok {
+			t.Fatalf("expected [%s] in label file", path)
+		}
+		if !reflect.DeepEqual(labels, []string{"cat", "indoor"}) {
+			t.Errorf("expected [cat indoor], but got %v", labels)
+		}
+	}
+
+	r := &ListRunner{labels: loaded}
+	entries := r.filterCopies([]listEntry{
+		{relPath: "cat/1.jpg"},
+		{relPath: "indoor/1.jpg"},
+		{relPath: "dog/2.jpg"},
+		{relPath: "bird/3.jpg"},
+	})
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.relPath)
+	}
+	if expected := []string{"cat/1.jpg", "dog/2.jpg", "bird/3.jpg"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, but got %v", expected, paths)
+	}
+}
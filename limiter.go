@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostLimiter limits concurrent requests and request rate per host.
+type hostLimiter struct {
+	maxParallel int
+	rate        rateLimit
+	burst       int
+
+	dataMu sync.Mutex
+	data   map[string]*hostState
+}
+
+type hostState struct {
+	sem    chan struct{}
+	bucket *tokenBucket
+}
+
+// newHostLimiter returns hostLimiter, and maxParallel=0 or empty rate means unlimited.
+func newHostLimiter(maxParallel int, rate rateLimit, burst int) *hostLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &hostLimiter{
+		maxParallel: maxParallel,
+		rate:        rate,
+		burst:       burst,
+		data:        make(map[string]*hostState),
+	}
+}
+
+// acquire waits for the slot of the host, and release must be called after the request.
+func (l *hostLimiter) acquire(host string) (release func()) {
+	if l.maxParallel <= 0 {
+		return func() {}
+	}
+
+	sem := l.getState(host).sem
+	sem <- struct{}{}
+	return func() {
+		<-sem
+	}
+}
+
+// reserve takes the token of the host and returns the duration to wait for it.
+// It must be called on each request including retry.
+func (l *hostLimiter) reserve(host string) time.Duration {
+	if l.rate.isEmpty() {
+		return 0
+	}
+	return l.getState(host).bucket.reserve()
+}
+
+func (l *hostLimiter) getState(host string) *hostState {
+	l.dataMu.Lock()
+	defer l.dataMu.Unlock()
+
+	st, ok := l.data[host]
+	if !ok {
+		st = &hostState{}
+		if l.maxParallel > 0 {
+			st.sem = make(chan struct{}, l.maxParallel)
+		}
+		if !l.rate.isEmpty() {
+			st.bucket = newTokenBucket(l.rate.perSecond(), l.burst)
+		}
+		l.data[host] = st
+	}
+	return st
+}
+
+// tokenBucket is a token bucket rate limiter.
+type tokenBucket struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, and returns the duration until the token is available.
+// Negative tokens are used as reservations for the waiting callers.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// requestSlot is a slot of the global parallel requests, which can be given back while waiting.
+// It is used by a single goroutine.
+type requestSlot struct {
+	sem  chan struct{}
+	held bool
+}
+
+func newRequestSlot(sem chan struct{}) *requestSlot {
+	return &requestSlot{
+		sem: sem,
+	}
+}
+
+// acquire waits for the slot, and does nothing when the slot is already held.
+func (s *requestSlot) acquire() {
+	if s.held {
+		return
+	}
+	s.sem <- struct{}{}
+	s.held = true
+}
+
+// release gives the slot back, and does nothing when the slot is not held.
+func (s *requestSlot) release() {
+	if !s.held {
+		return
+	}
+	<-s.sem
+	s.held = false
+}
+
+// rateLimit is request count per duration, and it is used for cli flag. (e.g. '10/s', '100/m')
+type rateLimit struct {
+	Count float64
+	Per   time.Duration
+}
+
+func (r *rateLimit) Decode(s string) error {
+	if s == "" || s == "0" {
+		*r = rateLimit{}
+		return nil
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	count, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || count < 0 {
+		return fmt.Errorf("invalid rate: [%s], use '<count>/<unit>' format (e.g. '10/s')", s)
+	}
+
+	per := time.Second
+	if len(parts) == 2 {
+		per, err = parseRateUnit(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid rate: [%s], %w", s, err)
+		}
+	}
+	*r = rateLimit{
+		Count: count,
+		Per:   per,
+	}
+	return nil
+}
+
+func (r rateLimit) isEmpty() bool {
+	return r.Count <= 0 || r.Per <= 0
+}
+
+func (r rateLimit) perSecond() float64 {
+	return r.Count / r.Per.Seconds()
+}
+
+// parseRateUnit parses unit of rate. (e.g. 's', 'm', 'h', '10s')
+func parseRateUnit(unit string) (time.Duration, error) {
+	switch unit {
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	d, err := time.ParseDuration(unit)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("unit must be 's', 'm', 'h' or duration")
+	}
+	return d, nil
+}
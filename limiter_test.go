@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitDecode(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected rateLimit
+		isErr    bool
+	}{
+		{"10/s", rateLimit{Count: 10, Per: time.Second}, false},
+		{"100/m", rateLimit{Count: 100, Per: time.Minute}, false},
+		{"1/500ms", rateLimit{Count: 1, Per: 500 * time.Millisecond}, false},
+		{"5", rateLimit{Count: 5, Per: time.Second}, false},
+		{"0", rateLimit{}, false},
+		{"", rateLimit{}, false},
+		{"a/s", rateLimit{}, true},
+		{"-1/s", rateLimit{}, true},
+		{"1/x", rateLimit{}, true},
+		{"1/-1s", rateLimit{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var r rateLimit
+			err := r.Decode(tt.value)
+			if tt.isErr {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r != tt.expected {
+				t.Errorf("expected %+v, but got %+v", tt.expected, r)
+			}
+		})
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	b := newTokenBucket(10, 2)
+
+	// burst tokens are available immediately, and the next ones are reserved with 100ms interval.
+	expected := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, e := range expected {
+		d := b.reserve()
+		if d < e-10*time.Millisecond || d > e {
+			t.Errorf("#%d: expected [%s], but got [%s]", i, e, d)
+		}
+	}
+}
+
+func TestHostLimiterReserve(t *testing.T) {
+	l := newHostLimiter(0, rateLimit{Count: 1, Per: time.Second}, 1)
+	if d := l.reserve("a.example.com"); d != 0 {
+		t.Errorf("expected no wait for the first request, but got [%s]", d)
+	}
+	if d := l.reserve("a.example.com"); d <= 0 {
+		t.Errorf("expected wait for the second request to the same host")
+	}
+	if d := l.reserve("b.example.com"); d != 0 {
+		t.Errorf("expected no wait for the other host, but got [%s]", d)
+	}
+
+	unlimited := newHostLimiter(0, rateLimit{}, 1)
+	for i := 0; i < 10; i++ {
+		if d := unlimited.reserve("a.example.com"); d != 0 {
+			t.Fatalf("expected no wait without rate, but got [%s]", d)
+		}
+	}
+}
+
+func TestHostLimiterAcquire(t *testing.T) {
+	l := newHostLimiter(2, rateLimit{}, 1)
+	release1 := l.acquire("a.example.com")
+	release2 := l.acquire("a.example.com")
+	releaseB := l.acquire("b.example.com")
+	defer releaseB()
+
+	acquired := make(chan struct{})
+	go func() {
+		release := l.acquire("a.example.com")
+		close(acquired)
+		release()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("acquired over max parallel")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release1()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("cannot acquire after release")
+	}
+	release2()
+}
+
+func TestRequestSlot(t *testing.T) {
+	sem := make(chan struct{}, 1)
+	s := newRequestSlot(sem)
+
+	s.acquire()
+	s.acquire()
+	if len(sem) != 1 {
+		t.Errorf("expected 1 slot in use, but got %d", len(sem))
+	}
+
+	s.release()
+	s.release()
+	if len(sem) != 0 {
+		t.Errorf("expected no slot in use, but got %d", len(sem))
+	}
+
+	// the other goroutine can take the slot while released.
+	other := newRequestSlot(sem)
+	other.acquire()
+	other.release()
+}
@@ -12,6 +12,7 @@ func main() {
 		cli.Tree(help),
 		cli.Tree(downloader),
 		cli.Tree(list),
+		cli.Tree(puller),
 		cli.Tree(uploader),
 		cli.Tree(vott),
 	).Run(os.Args[1:]); err != nil {
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// manifestEntry is a record of the uploaded file.
+type manifestEntry struct {
+	Path       string    `json:"path"`
+	Bucket     string    `json:"bucket"`
+	ObjectPath string    `json:"object_path"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mtime"`
+	MD5        string    `json:"md5"`
+	IsDeleted  bool      `json:"deleted,omitempty"`
+}
+
+func newManifestEntry(srcPath, bucket, objectPath string) (manifestEntry, error) {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	return manifestEntry{
+		Path:       srcPath,
+		Bucket:     bucket,
+		ObjectPath: objectPath,
+		Size:       info.Size(),
+		ModTime:    info.ModTime(),
+	}, nil
+}
+
+func (e manifestEntry) key() string {
+	return path.Join(e.Bucket, e.ObjectPath)
+}
+
+// setMD5 calculates MD5 hash of the local file.
+func (e *manifestEntry) setMD5() error {
+	if e.MD5 != "" {
+		return nil
+	}
+	sum, err := getFileChecksum(e.Path)
+	if err != nil {
+		return err
+	}
+	e.MD5 = sum.MD5
+	return nil
+}
+
+// isSame checks the local file is not modified since the previous upload.
+// MD5 hash is calculated only when mtime is changed.
+func (e *manifestEntry) isSame(prev manifestEntry) (bool, error) {
+	switch {
+	case e.Size != prev.Size:
+		return false, nil
+	case e.ModTime.Equal(prev.ModTime):
+		return true, nil
+	}
+
+	if err := e.setMD5(); err != nil {
+		return false, err
+	}
+	return e.MD5 == prev.MD5, nil
+}
+
+// uploadManifest records uploaded files into JSON Lines file.
+type uploadManifest struct {
+	dataMu sync.RWMutex
+	data   map[string]manifestEntry
+
+	fileMu sync.Mutex
+	file   string
+	fp     *os.File
+}
+
+// newUploadManifest loads entries from the manifest file and opens it to append new entries.
+func newUploadManifest(file string) (*uploadManifest, error) {
+	m, err := loadUploadManifest(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	m.fp = fp
+	return m, nil
+}
+
+// loadUploadManifest loads entries from the manifest file as read-only.
+func loadUploadManifest(file string) (*uploadManifest, error) {
+	m := &uploadManifest{
+		data: make(map[string]manifestEntry),
+		file: file,
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *uploadManifest) load() error {
+	fp, err := os.Open(m.file)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	defer fp.Close() //nolint
+
+	// the later entry overwrites the former one.
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var e manifestEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			// ignore a broken line written by interrupted run.
+			continue
+		}
+		if e.IsDeleted {
+			delete(m.data, e.key())
+			continue
+		}
+		m.data[e.key()] = e
+	}
+	return scanner.Err()
+}
+
+func (m *uploadManifest) get(key string) (manifestEntry, bool) {
+	m.dataMu.RLock()
+	defer m.dataMu.RUnlock()
+	e, ok := m.data[key]
+	return e, ok
+}
+
+// add adds the entry and appends it into the file immediately, to resume the interrupted run.
+func (m *uploadManifest) add(e manifestEntry) error {
+	m.dataMu.Lock()
+	m.data[e.key()] = e
+	m.dataMu.Unlock()
+	return m.write(e)
+}
+
+// remove removes the entry of the deleted object.
+func (m *uploadManifest) remove(bucket, objectPath string) error {
+	e := manifestEntry{
+		Bucket:     bucket,
+		ObjectPath: objectPath,
+		IsDeleted:  true,
+	}
+
+	m.dataMu.Lock()
+	delete(m.data, e.key())
+	m.dataMu.Unlock()
+	return m.write(e)
+}
+
+func (m *uploadManifest) write(e manifestEntry) error {
+	byt, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+	_, err = m.fp.Write(append(byt, '\n'))
+	return err
+}
+
+// Close rewrites the manifest file without duplicate entries.
+func (m *uploadManifest) Close() error {
+	if m.fp == nil {
+		return nil
+	}
+	if err := m.fp.Close(); err != nil {
+		return err
+	}
+
+	m.dataMu.RLock()
+	defer m.dataMu.RUnlock()
+	keys := make([]string, 0, len(m.data))
+	for k := range m.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for _, k := range keys {
+		if err := enc.Encode(m.data[k]); err != nil {
+			return err
+		}
+	}
+	return provider.WriteFile(m.file, buf)
+}
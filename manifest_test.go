@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUploadManifest(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "manifest.jsonl")
+
+	m, err := newUploadManifest(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries := []manifestEntry{
+		{Path: "a/1.jpg", Bucket: "b", ObjectPath: "p/1.jpg", Size: 1, MD5: "x"},
+		{Path: "a/2.jpg", Bucket: "b", ObjectPath: "p/2.jpg", Size: 2, MD5: "y"},
+		{Path: "a/1.jpg", Bucket: "b", ObjectPath: "p/1.jpg", Size: 3, MD5: "z"},
+	}
+	for _, e := range entries {
+		if err := m.add(e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := m.remove("b", "p/2.jpg"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := loadUploadManifest(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e, ok := loaded.get("b/p/1.jpg")
+	if !ok {
+		t.Fatalf("entry is not found")
+	}
+	if e.Size != 3 || e.MD5 != "z" {
+		t.Errorf("the later entry should overwrite the former one: %+v", e)
+	}
+	if _, ok := loaded.get("b/p/2.jpg"); ok {
+		t.Errorf("removed entry should not be loaded")
+	}
+}
+
+func TestUploadManifestIgnoreBrokenLine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "manifest.jsonl")
+	content := `{"path":"a/1.jpg","bucket":"b","object_path":"p/1.jpg","size":1}` + "\n" + `{"path":"a/2.jp`
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, err := loadUploadManifest(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := m.get("b/p/1.jpg"); !ok {
+		t.Errorf("entry is not found")
+	}
+	if len(m.data) != 1 {
+		t.Errorf("expected 1 entry, but got %d", len(m.data))
+	}
+}
+
+func TestManifestEntryIsSame(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "1.jpg")
+	if err := os.WriteFile(file, []byte("abc"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// MD5 of "abc"
+	const md5 = "900150983cd24fb0d6963f7d28e17f72"
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		prev     manifestEntry
+		expected bool
+	}{
+		{"same mtime", manifestEntry{Size: 3, ModTime: now}, true},
+		{"different size", manifestEntry{Size: 4, ModTime: now}, false},
+		{"touched", manifestEntry{Size: 3, ModTime: now.Add(-time.Hour), MD5: md5}, true},
+		{"modified", manifestEntry{Size: 3, ModTime: now.Add(-time.Hour), MD5: "x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := manifestEntry{Path: file, Size: 3, ModTime: now}
+			result, err := e.isSame(tt.prev)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %v, but got %v", tt.expected, result)
+			}
+		})
+	}
+}
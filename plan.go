@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// operations of the upload plan.
+const (
+	planUpload   = "upload"
+	planSkip     = "skip"
+	planConflict = "conflict"
+	planDelete   = "delete"
+)
+
+// uploadPlan is a planned operation for a file on --dry-run.
+type uploadPlan struct {
+	Operation  string `json:"operation"`
+	Path       string `json:"path"`
+	ObjectPath string `json:"object_path"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+func (p uploadPlan) String() string {
+	return fmt.Sprintf("[DRYRUN] %s path=[%s] object=[%s] reason=[%s]", p.Operation, p.Path, p.ObjectPath, p.Reason)
+}
+
+// uploadPlanList contains plans of all of the files.
+type uploadPlanList struct {
+	listMu sync.Mutex
+	list   []uploadPlan
+}
+
+func (l *uploadPlanList) add(p uploadPlan) {
+	fmt.Println(p.String())
+
+	l.listMu.Lock()
+	defer l.listMu.Unlock()
+	l.list = append(l.list, p)
+}
+
+// WriteFile writes plans into the file.
+// The format is decided by file extension. (.csv, .json or .jsonl)
+func (l *uploadPlanList) WriteFile(file string) error {
+	l.listMu.Lock()
+	defer l.listMu.Unlock()
+
+	list := l.list
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].ObjectPath < list[j].ObjectPath
+	})
+
+	buf := new(bytes.Buffer)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		enc := json.NewEncoder(buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			return err
+		}
+	case ".jsonl":
+		enc := json.NewEncoder(buf)
+		for _, p := range list {
+			if err := enc.Encode(p); err != nil {
+				return err
+			}
+		}
+	default:
+		w := csv.NewWriter(buf)
+		_ = w.Write([]string{"operation", "path", "object_path", "reason"})
+		for _, p := range list {
+			_ = w.Write([]string{p.Operation, p.Path, p.ObjectPath, p.Reason})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return provider.WriteFile(file, buf)
+}
@@ -0,0 +1,198 @@
+package azblob
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+const providerName = "azblob"
+
+// environment variables for Azure Blob Storage.
+const (
+	envAccount  = "AZURE_STORAGE_ACCOUNT"
+	envKey      = "AZURE_STORAGE_KEY"
+	envEndpoint = "AZURE_STORAGE_ENDPOINT"
+)
+
+func init() {
+	provider.AddProvider(providerName, newProvider)
+}
+
+// Client is client for Azure Blob Storage.
+type Client struct {
+	azblob.ServiceURL
+}
+
+// New returns initialized Client from env vars.
+// AZURE_STORAGE_ENDPOINT can be used for emulators like Azurite. (e.g. 'http://127.0.0.1:10000/devstoreaccount1')
+func New() (Client, error) {
+	account := os.Getenv(envAccount)
+	u, err := getServiceURL(account, os.Getenv(envEndpoint))
+	if err != nil {
+		return Client{}, err
+	}
+	cred, err := azblob.NewSharedKeyCredential(account, os.Getenv(envKey))
+	if err != nil {
+		return Client{}, err
+	}
+
+	p := azblob.NewPipeline(cred, azblob.PipelineOptions{})
+	return Client{
+		ServiceURL: azblob.NewServiceURL(*u, p),
+	}, nil
+}
+
+// getServiceURL returns URL of the Blob service from the account, or the endpoint when it is set.
+func getServiceURL(account, endpoint string) (*url.URL, error) {
+	if account == "" {
+		return nil, fmt.Errorf("%s is empty", envAccount)
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", account)
+	}
+
+	u, err := url.Parse(endpoint)
+	switch {
+	case err != nil:
+		return nil, err
+	case u.Scheme == "" || u.Host == "":
+		return nil, fmt.Errorf("invalid %s: [%s]", envEndpoint, endpoint)
+	}
+	return u, nil
+}
+
+func newProvider() (provider.Provider, error) {
+	return New()
+}
+
+// CheckBucket checks container existence.
+func (c Client) CheckBucket(bucketName string) error {
+	_, err := c.ServiceURL.NewContainerURL(bucketName).GetProperties(context.Background(), azblob.LeaseAccessConditions{})
+	return err
+}
+
+// IsExists checks file existence from Azure Blob Storage container.
+func (c Client) IsExists(opt provider.FileOption) (isExist bool, err error) {
+	_, err = c.getBlobURL(opt).GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	switch {
+	case isNotFound(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+// UploadFromLocalFile uploads from local file to Azure Blob Storage container.
+func (c Client) UploadFromLocalFile(opt provider.FileOption) error {
+	file, err := os.Open(opt.SrcPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint
+
+	_, err = azblob.UploadFileToBlockBlob(context.Background(), file, c.getBlobURL(opt).ToBlockBlobURL(), azblob.UploadToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
+			ContentType: mime.TypeByExtension(filepath.Ext(opt.SrcPath)),
+		},
+	})
+	return err
+}
+
+// GetAttributes gets size and checksum of the blob from Azure Blob Storage container.
+func (c Client) GetAttributes(opt provider.FileOption) (provider.Object, error) {
+	resp, err := c.getBlobURL(opt).GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return provider.Object{}, err
+	}
+
+	return provider.Object{
+		Path: opt.DstPath,
+		Size: resp.ContentLength(),
+		MD5:  hex.EncodeToString(resp.ContentMD5()),
+	}, nil
+}
+
+// Delete deletes the blob from Azure Blob Storage container.
+func (c Client) Delete(opt provider.FileOption) error {
+	_, err := c.getBlobURL(opt).Delete(context.Background(), azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	return err
+}
+
+// IsRetryableError checks the error is transient error like throttling. (e.g. 503 Server Busy)
+func (c Client) IsRetryableError(err error) bool {
+	var stgErr azblob.StorageError
+	if errors.As(err, &stgErr) && stgErr.Response() != nil {
+		return provider.IsRetryableStatus(stgErr.Response().StatusCode)
+	}
+	return provider.IsNetworkError(err)
+}
+
+// List lists blobs from Azure Blob Storage container.
+func (c Client) List(opt provider.ListOption) ([]provider.Object, error) {
+	containerURL := c.ServiceURL.NewContainerURL(opt.BucketName)
+
+	var list []provider.Object
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := containerURL.ListBlobsFlatSegment(context.Background(), marker, azblob.ListBlobsSegmentOptions{
+			Prefix: opt.Prefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			if strings.HasSuffix(item.Name, "/") {
+				continue
+			}
+
+			var size int64
+			if item.Properties.ContentLength != nil {
+				size = *item.Properties.ContentLength
+			}
+			list = append(list, provider.Object{
+				Path: item.Name,
+				Size: size,
+			})
+		}
+		marker = resp.NextMarker
+	}
+	return list, nil
+}
+
+// Download downloads from Azure Blob Storage container to local file.
+func (c Client) Download(opt provider.FileOption) error {
+	blobURL := c.ServiceURL.NewContainerURL(opt.BucketName).NewBlobURL(opt.SrcPath)
+	resp, err := blobURL.Download(context.Background(), 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return err
+	}
+
+	body := resp.Body(azblob.RetryReaderOptions{})
+	defer body.Close() //nolint
+
+	return provider.WriteFile(opt.DstPath, body)
+}
+
+func (c Client) getBlobURL(opt provider.FileOption) azblob.BlobURL {
+	return c.ServiceURL.NewContainerURL(opt.BucketName).NewBlobURL(opt.DstPath)
+}
+
+func isNotFound(err error) bool {
+	var stgErr azblob.StorageError
+	if !errors.As(err, &stgErr) {
+		return false
+	}
+	return stgErr.Response() != nil && stgErr.Response().StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,173 @@
+package azblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+func TestGetServiceURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		account  string
+		endpoint string
+		expected string
+		isErr    bool
+	}{
+		{"account", "myaccount", "", "https://myaccount.blob.core.windows.net/", false},
+		{"endpoint", "devstoreaccount1", "http://127.0.0.1:10000/devstoreaccount1", "http://127.0.0.1:10000/devstoreaccount1", false},
+		{"empty account", "", "", "", true},
+		{"endpoint without scheme", "devstoreaccount1", "127.0.0.1:10000/devstoreaccount1", "", true},
+		{"endpoint without host", "devstoreaccount1", "/devstoreaccount1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := getServiceURL(tt.account, tt.endpoint)
+			if tt.isErr {
+				if err == nil {
+					t.Errorf("expected error, but got [%s]", u)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if u.String() != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, u)
+			}
+		})
+	}
+}
+
+// testStorageError is azblob.StorageError with the status code.
+type testStorageError struct {
+	azblob.StorageError
+	statusCode int
+}
+
+func (e testStorageError) Error() string {
+	return fmt.Sprintf("status code: [%d]", e.statusCode)
+}
+
+func (e testStorageError) Response() *http.Response {
+	return &http.Response{StatusCode: e.statusCode}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"503", testStorageError{statusCode: 503}, true},
+		{"500", testStorageError{statusCode: 500}, true},
+		{"429", testStorageError{statusCode: 429}, true},
+		{"404", testStorageError{statusCode: 404}, false},
+		{"403", testStorageError{statusCode: 403}, false},
+		{"wrapped 503", fmt.Errorf("upload: %w", testStorageError{statusCode: 503}), true},
+		{"other", errors.New("error"), false},
+	}
+
+	c := Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := c.IsRetryableError(tt.err); result != tt.expected {
+				t.Errorf("expected %v, but got %v", tt.expected, result)
+			}
+		})
+	}
+
+	if !isNotFound(fmt.Errorf("get: %w", testStorageError{statusCode: 404})) {
+		t.Errorf("expected not found for 404")
+	}
+}
+
+// TestClientWithEmulator runs against the emulator like Azurite, and it is skipped when AZURE_STORAGE_ENDPOINT is not set.
+// (e.g.) AZURE_STORAGE_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1 AZURE_STORAGE_ACCOUNT=devstoreaccount1 AZURE_STORAGE_KEY=<key>
+func TestClientWithEmulator(t *testing.T) {
+	if os.Getenv(envEndpoint) == "" {
+		t.Skipf("%s is not set", envEndpoint)
+	}
+
+	c, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bucket := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	containerURL := c.ServiceURL.NewContainerURL(bucket)
+	if _, err := containerURL.Create(context.Background(), azblob.Metadata{}, azblob.PublicAccessNone); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer containerURL.Delete(context.Background(), azblob.ContainerAccessConditions{}) //nolint:errcheck
+
+	if err := c.CheckBucket(bucket); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "1.jpg")
+	if err := os.WriteFile(src, []byte("abc"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opt := provider.FileOption{
+		SrcPath:    src,
+		BucketName: bucket,
+		DstPath:    "cat/1.jpg",
+	}
+
+	if isExist, err := c.IsExists(opt); err != nil || isExist {
+		t.Fatalf("expected not exist, but got [%v] err=[%v]", isExist, err)
+	}
+	if err := c.UploadFromLocalFile(opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isExist, err := c.IsExists(opt); err != nil || !isExist {
+		t.Fatalf("expected exist, but got [%v] err=[%v]", isExist, err)
+	}
+
+	obj, err := c.GetAttributes(opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// MD5 of 'abc'
+	if obj.Size != 3 || obj.MD5 != "900150983cd24fb0d6963f7d28e17f72" {
+		t.Errorf("unexpected attributes: %v", obj)
+	}
+
+	list, err := c.List(provider.ListOption{BucketName: bucket, Prefix: "cat/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list) != 1 || list[0].Path != "cat/1.jpg" || list[0].Size != 3 {
+		t.Errorf("unexpected list: %v", list)
+	}
+
+	dst := filepath.Join(dir, "downloaded.jpg")
+	if err := c.Download(provider.FileOption{SrcPath: "cat/1.jpg", BucketName: bucket, DstPath: dst}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byt, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(byt) != "abc" {
+		t.Errorf("expected [abc], but got [%s]", byt)
+	}
+
+	if err := c.Delete(opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isExist, err := c.IsExists(opt); err != nil || isExist {
+		t.Errorf("expected not exist after delete, but got [%v] err=[%v]", isExist, err)
+	}
+}
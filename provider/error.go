@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// IsRetryableStatus checks the HTTP status code is transient error. (e.g. throttling)
+func IsRetryableStatus(code int) bool {
+	switch {
+	case code == http.StatusTooManyRequests,
+		code == http.StatusRequestTimeout:
+		return true
+	case code == http.StatusNotImplemented:
+		return false
+	}
+	return code >= 500
+}
+
+// IsNetworkError checks the error is transient network error.
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// suffix of the temporary files, which are left after crash. (e.g. '.1.jpg.123456.tmp')
+const tempFileSuffix = ".tmp"
+
+// IsTempFile checks the file is the temporary file of WriteFile, to skip it on listing files.
+func IsTempFile(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempFileSuffix)
+}
+
+// AtomicFile is the temporary file which is renamed to the path on Commit,
+// so partially written file never exists on the path.
+type AtomicFile struct {
+	*os.File
+	path string
+}
+
+// CreateFile creates the temporary file in the dir of the path.
+func CreateFile(path string) (*AtomicFile, error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	fp, err := ioutil.TempFile(dir, "."+name+".*"+tempFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{
+		File: fp,
+		path: path,
+	}, nil
+}
+
+// Commit closes the temporary file and renames it to the path.
+// The temporary file is removed on error.
+func (f *AtomicFile) Commit() error {
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name()) //nolint
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name()) //nolint
+		return err
+	}
+	return nil
+}
+
+// Abort closes and removes the temporary file.
+func (f *AtomicFile) Abort() {
+	f.Close()           //nolint
+	os.Remove(f.Name()) //nolint
+}
+
+// WriteFile writes data from r into the temporary file and renames it to the path,
+// so partially written file never exists on the path.
+func WriteFile(path string, r io.Reader) error {
+	f, err := CreateFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsTempFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"cat/.1.jpg.123456.tmp", true},
+		{".1.jpg.download.tmp", true},
+		{"cat/1.jpg", false},
+		{"cat/1.tmp", false},
+		{"cat/.hidden", false},
+	}
+	for _, tt := range tests {
+		if result := IsTempFile(tt.path); result != tt.expected {
+			t.Errorf("path=[%s]: expected %v, but got %v", tt.path, tt.expected, result)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "1.txt")
+	if err := WriteFile(file, strings.NewReader("abc")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	byt, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(byt) != "abc" {
+		t.Errorf("expected [abc], but got [%s]", byt)
+	}
+
+	// the temporary file must be removed.
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only 1 file, but got %d files", len(files))
+	}
+}
@@ -2,9 +2,16 @@ package gcs
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
 
+	GCP "cloud.google.com/go/storage"
 	"github.com/evalphobia/google-api-go-wrapper/config"
 	"github.com/evalphobia/google-api-go-wrapper/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 
 	"github.com/evalphobia/cloud-label-uploader/provider"
 )
@@ -13,6 +20,7 @@ const providerName = "gcs"
 
 func init() {
 	provider.AddProvider(providerName, newProvider)
+	provider.AddScheme("gs", providerName)
 }
 
 // Client is client for Google Cloud Storage.
@@ -52,3 +60,70 @@ func (c Client) UploadFromLocalFile(opt provider.FileOption) error {
 		Path:       opt.DstPath,
 	})
 }
+
+// GetAttributes gets size and checksum of the object from GCS Bucket.
+func (c Client) GetAttributes(opt provider.FileOption) (provider.Object, error) {
+	attrs, err := c.Storage.Bucket(opt.BucketName).Object(opt.DstPath).Attrs(context.Background())
+	if err != nil {
+		return provider.Object{}, err
+	}
+
+	return provider.Object{
+		Path:   opt.DstPath,
+		Size:   attrs.Size,
+		MD5:    hex.EncodeToString(attrs.MD5),
+		CRC32C: fmt.Sprintf("%08x", attrs.CRC32C),
+	}, nil
+}
+
+// Delete deletes the object from GCS Bucket.
+func (c Client) Delete(opt provider.FileOption) error {
+	return c.Storage.Delete(storage.ObjectOption{
+		BucketName: opt.BucketName,
+		Path:       opt.DstPath,
+	})
+}
+
+// IsRetryableError checks the error is transient error like throttling. (e.g. 429 Too Many Requests)
+func (c Client) IsRetryableError(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return provider.IsRetryableStatus(apiErr.Code)
+	}
+	return provider.IsNetworkError(err)
+}
+
+// List lists objects from GCS Bucket.
+func (c Client) List(opt provider.ListOption) ([]provider.Object, error) {
+	it := c.Storage.Bucket(opt.BucketName).Objects(context.Background(), &GCP.Query{
+		Prefix: opt.Prefix,
+	})
+
+	var list []provider.Object
+	for {
+		attrs, err := it.Next()
+		switch {
+		case err == iterator.Done:
+			return list, nil
+		case err != nil:
+			return nil, err
+		case strings.HasSuffix(attrs.Name, "/"):
+			continue
+		}
+		list = append(list, provider.Object{
+			Path: attrs.Name,
+			Size: attrs.Size,
+		})
+	}
+}
+
+// Download downloads from GCS Bucket to local file.
+func (c Client) Download(opt provider.FileOption) error {
+	r, err := c.Storage.Bucket(opt.BucketName).Object(opt.SrcPath).NewReader(context.Background())
+	if err != nil {
+		return err
+	}
+	defer r.Close() //nolint
+
+	return provider.WriteFile(opt.DstPath, r)
+}
@@ -0,0 +1,32 @@
+package gcs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"503", &googleapi.Error{Code: 503}, true},
+		{"429", &googleapi.Error{Code: 429}, true},
+		{"404", &googleapi.Error{Code: 404}, false},
+		{"wrapped 503", fmt.Errorf("upload: %w", &googleapi.Error{Code: 503}), true},
+		{"other", errors.New("error"), false},
+	}
+
+	c := Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := c.IsRetryableError(tt.err); result != tt.expected {
+				t.Errorf("expected %v, but got %v", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package local
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+const providerName = "local"
+
+func init() {
+	provider.AddProvider(providerName, newProvider)
+}
+
+// Client is client for local filesystem (or mounted volume).
+// Bucket name is used as a root directory.
+type Client struct{}
+
+func New() (Client, error) {
+	return Client{}, nil
+}
+
+func newProvider() (provider.Provider, error) {
+	return New()
+}
+
+// CheckBucket checks root directory existence.
+func (c Client) CheckBucket(bucketName string) error {
+	info, err := os.Stat(bucketName)
+	switch {
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return fmt.Errorf("bucket is not a directory: [%s]", bucketName)
+	}
+	return nil
+}
+
+// IsExists checks file existence from the root directory.
+func (c Client) IsExists(opt provider.FileOption) (isExist bool, err error) {
+	_, err = os.Stat(getPath(opt))
+	switch {
+	case os.IsNotExist(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+// UploadFromLocalFile copies from local file to the root directory.
+// The file is written atomically, so interrupted copy does not leave partial file.
+func (c Client) UploadFromLocalFile(opt provider.FileOption) error {
+	src, err := os.Open(opt.SrcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close() //nolint
+
+	dstPath := getPath(opt)
+	if err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		return err
+	}
+	return provider.WriteFile(dstPath, src)
+}
+
+// GetAttributes gets size and MD5 checksum of the file from the root directory.
+func (c Client) GetAttributes(opt provider.FileOption) (provider.Object, error) {
+	fp, err := os.Open(getPath(opt))
+	if err != nil {
+		return provider.Object{}, err
+	}
+	defer fp.Close() //nolint
+
+	h := md5.New() //nolint:gosec
+	size, err := io.Copy(h, fp)
+	if err != nil {
+		return provider.Object{}, err
+	}
+	return provider.Object{
+		Path: opt.DstPath,
+		Size: size,
+		MD5:  hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// Delete deletes the file from the root directory.
+func (c Client) Delete(opt provider.FileOption) error {
+	return os.Remove(getPath(opt))
+}
+
+// IsRetryableError always returns false, as local filesystem does not have transient error.
+func (c Client) IsRetryableError(err error) bool {
+	return false
+}
+
+// List lists files from the root directory.
+func (c Client) List(opt provider.ListOption) ([]provider.Object, error) {
+	root := filepath.Clean(opt.BucketName)
+
+	// walk only the directory containing the prefix.
+	dir := root
+	if i := strings.LastIndex(opt.Prefix, "/"); i != -1 {
+		dir = filepath.Join(root, filepath.FromSlash(opt.Prefix[:i]))
+	}
+
+	var list []provider.Object
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		case info.IsDir(),
+			provider.IsTempFile(path):
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		objectPath := filepath.ToSlash(rel)
+		if !strings.HasPrefix(objectPath, opt.Prefix) {
+			return nil
+		}
+		list = append(list, provider.Object{
+			Path: objectPath,
+			Size: info.Size(),
+		})
+		return nil
+	})
+	return list, err
+}
+
+// Download copies from the root directory to local file.
+func (c Client) Download(opt provider.FileOption) error {
+	src, err := os.Open(filepath.Join(opt.BucketName, filepath.FromSlash(opt.SrcPath)))
+	if err != nil {
+		return err
+	}
+	defer src.Close() //nolint
+
+	return provider.WriteFile(opt.DstPath, src)
+}
+
+func getPath(opt provider.FileOption) string {
+	return filepath.Join(opt.BucketName, filepath.FromSlash(opt.DstPath))
+}
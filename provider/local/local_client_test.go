@@ -0,0 +1,175 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckBucket(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	writeTestFile(t, file, "abc")
+
+	tests := []struct {
+		name   string
+		bucket string
+		isErr  bool
+	}{
+		{"dir", root, false},
+		{"file", file, true},
+		{"not exist", filepath.Join(root, "none"), true},
+	}
+
+	c := Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.CheckBucket(tt.bucket)
+			if tt.isErr != (err != nil) {
+				t.Errorf("expected error=[%t], but got [%v]", tt.isErr, err)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	root := t.TempDir()
+	srcDir := t.TempDir()
+	src := filepath.Join(srcDir, "1.jpg")
+	writeTestFile(t, src, "abc")
+
+	c := Client{}
+	opt := provider.FileOption{
+		SrcPath:    src,
+		BucketName: root,
+		DstPath:    "prefix/cat/1.jpg",
+	}
+
+	if isExist, err := c.IsExists(opt); err != nil || isExist {
+		t.Fatalf("expected not exist, but got [%v] err=[%v]", isExist, err)
+	}
+	if err := c.UploadFromLocalFile(opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isExist, err := c.IsExists(opt); err != nil || !isExist {
+		t.Fatalf("expected exist, but got [%v] err=[%v]", isExist, err)
+	}
+
+	obj, err := c.GetAttributes(opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// MD5 of 'abc'
+	expected := provider.Object{Path: "prefix/cat/1.jpg", Size: 3, MD5: "900150983cd24fb0d6963f7d28e17f72"}
+	if obj != expected {
+		t.Errorf("expected %v, but got %v", expected, obj)
+	}
+
+	dst := filepath.Join(srcDir, "downloaded.jpg")
+	if err := c.Download(provider.FileOption{SrcPath: "prefix/cat/1.jpg", BucketName: root, DstPath: dst}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if byt, err := os.ReadFile(dst); err != nil || string(byt) != "abc" {
+		t.Errorf("expected [abc], but got [%s] err=[%v]", byt, err)
+	}
+
+	if err := c.Delete(opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isExist, err := c.IsExists(opt); err != nil || isExist {
+		t.Errorf("expected not exist after delete, but got [%v] err=[%v]", isExist, err)
+	}
+}
+
+func TestUploadFromLocalFileError(t *testing.T) {
+	root := t.TempDir()
+	c := Client{}
+	opt := provider.FileOption{
+		SrcPath:    filepath.Join(t.TempDir(), "none.jpg"),
+		BucketName: root,
+		DstPath:    "cat/1.jpg",
+	}
+	if err := c.UploadFromLocalFile(opt); err == nil {
+		t.Fatalf("expected error")
+	}
+	if isExist, _ := c.IsExists(opt); isExist {
+		t.Errorf("expected no file on error")
+	}
+	if c.IsRetryableError(os.ErrNotExist) {
+		t.Errorf("expected not retryable")
+	}
+
+	// the existing file is replaced atomically, and temporary files are not left.
+	src := filepath.Join(t.TempDir(), "1.jpg")
+	writeTestFile(t, src, "new")
+	writeTestFile(t, filepath.Join(root, "cat", "1.jpg"), "old")
+	opt.SrcPath = src
+	if err := c.UploadFromLocalFile(opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files, err := os.ReadDir(filepath.Join(root, "cat"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only 1 file, but got %d files", len(files))
+	}
+	if byt, _ := os.ReadFile(filepath.Join(root, "cat", "1.jpg")); string(byt) != "new" {
+		t.Errorf("expected [new], but got [%s]", byt)
+	}
+}
+
+func TestList(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "prefix", "cat", "1.jpg"), "a")
+	writeTestFile(t, filepath.Join(root, "prefix", "cat", ".1.jpg.123456.tmp"), "a")
+	writeTestFile(t, filepath.Join(root, "prefix", "dog", "2.jpg"), "bb")
+	writeTestFile(t, filepath.Join(root, "prefix2", "3.jpg"), "c")
+	writeTestFile(t, filepath.Join(root, "4.jpg"), "d")
+
+	tests := []struct {
+		prefix   string
+		expected []provider.Object
+	}{
+		{"prefix/", []provider.Object{
+			{Path: "prefix/cat/1.jpg", Size: 1},
+			{Path: "prefix/dog/2.jpg", Size: 2},
+		}},
+		{"prefix/cat/", []provider.Object{
+			{Path: "prefix/cat/1.jpg", Size: 1},
+		}},
+		{"prefix", []provider.Object{
+			{Path: "prefix/cat/1.jpg", Size: 1},
+			{Path: "prefix/dog/2.jpg", Size: 2},
+			{Path: "prefix2/3.jpg", Size: 1},
+		}},
+		{"none/", nil},
+	}
+
+	c := Client{}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			list, err := c.List(provider.ListOption{BucketName: root, Prefix: tt.prefix})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(list, tt.expected) {
+				t.Errorf("expected %v, but got %v", tt.expected, list)
+			}
+		})
+	}
+}
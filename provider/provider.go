@@ -7,18 +7,44 @@ import (
 
 var providerGenerator = map[string]func() (Provider, error){}
 
+// URI scheme => provider name. (e.g. 's3' => 's3', 'gs' => 'gcs')
+var providerScheme = map[string]string{}
+
 type Provider interface {
 	CheckBucket(bucketName string) error
 	IsExists(FileOption) (isExist bool, err error)
 	UploadFromLocalFile(FileOption) error
+	List(ListOption) ([]Object, error)
+	Download(FileOption) error
+	GetAttributes(FileOption) (Object, error)
+	Delete(FileOption) error
+	IsRetryableError(error) bool
 }
 
+// FileOption is used for file operations.
+// SrcPath is local file path and DstPath is object path on upload,
+// and SrcPath is object path and DstPath is local file path on download.
 type FileOption struct {
 	SrcPath    string
 	BucketName string
 	DstPath    string
 }
 
+// ListOption is used for listing objects.
+type ListOption struct {
+	BucketName string
+	Prefix     string
+}
+
+// Object is an object in the bucket.
+// MD5 and CRC32C are hex encoded checksums, and empty when the provider does not support it.
+type Object struct {
+	Path   string
+	Size   int64
+	MD5    string
+	CRC32C string
+}
+
 // AddProvider adds the Provider constructor to the list.
 func AddProvider(providerName string, fn func() (Provider, error)) {
 	providerGenerator[providerName] = fn
@@ -31,3 +57,14 @@ func Create(providerName string) (Provider, error) {
 	}
 	return nil, fmt.Errorf("unknown provider: [%s]", providerName)
 }
+
+// AddScheme adds URI scheme for the Provider. (e.g. 'gs' for 'gs://bucket/path/to/file')
+func AddScheme(scheme, providerName string) {
+	providerScheme[scheme] = providerName
+}
+
+// GetProviderNameByScheme returns provider name of the URI scheme.
+func GetProviderNameByScheme(scheme string) (providerName string, ok bool) {
+	providerName, ok = providerScheme[strings.ToLower(scheme)]
+	return providerName, ok
+}
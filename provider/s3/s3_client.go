@@ -1,9 +1,14 @@
 package s3
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	SDK "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/evalphobia/aws-sdk-go-wrapper/config"
 	"github.com/evalphobia/aws-sdk-go-wrapper/s3"
 
@@ -14,18 +19,30 @@ const providerName = "s3"
 
 func init() {
 	provider.AddProvider(providerName, newProvider)
+	provider.AddScheme("s3", providerName)
 }
 
 // Client is client for AWS S3.
+// sdk is used for the operations which the wrapper does not support. (e.g. streaming download)
 type Client struct {
 	*s3.S3
+	sdk *SDK.S3
 }
 
 func New() (Client, error) {
-	cli, err := s3.New(config.Config{})
+	conf := config.Config{}
+	cli, err := s3.New(conf)
+	if err != nil {
+		return Client{}, err
+	}
+	sess, err := conf.Session()
+	if err != nil {
+		return Client{}, err
+	}
 	return Client{
-		S3: cli,
-	}, err
+		S3:  cli,
+		sdk: SDK.New(sess),
+	}, nil
 }
 
 func newProvider() (provider.Provider, error) {
@@ -69,3 +86,125 @@ func (c Client) UploadFromLocalFile(opt provider.FileOption) error {
 	err = b.PutOne(obj, opt.DstPath, s3.ACLPrivate)
 	return err
 }
+
+// GetAttributes gets size and checksum of the object from S3 Bucket.
+func (c Client) GetAttributes(opt provider.FileOption) (provider.Object, error) {
+	b, err := c.S3.GetBucket(opt.BucketName)
+	if err != nil {
+		return provider.Object{}, err
+	}
+
+	out, err := b.HeadObject(opt.DstPath)
+	if err != nil {
+		return provider.Object{}, err
+	}
+
+	obj := provider.Object{
+		Path: opt.DstPath,
+	}
+	if out.ContentLength != nil {
+		obj.Size = *out.ContentLength
+	}
+	if isETagMD5(out) {
+		obj.MD5 = strings.Trim(*out.ETag, `"`)
+	}
+	return obj, nil
+}
+
+// isETagMD5 checks the ETag is MD5 of the object.
+// ETag is not MD5 for multipart upload (e.g. "<hash>-<parts>"), SSE-KMS and SSE-C.
+func isETagMD5(out *SDK.HeadObjectOutput) bool {
+	switch {
+	case out.ETag == nil,
+		strings.Contains(*out.ETag, "-"),
+		out.SSECustomerAlgorithm != nil:
+		return false
+	case out.ServerSideEncryption == nil:
+		return true
+	}
+	return *out.ServerSideEncryption == SDK.ServerSideEncryptionAes256
+}
+
+// Delete deletes the object from S3 Bucket.
+func (c Client) Delete(opt provider.FileOption) error {
+	b, err := c.S3.GetBucket(opt.BucketName)
+	if err != nil {
+		return err
+	}
+	return b.DeleteObject(opt.DstPath)
+}
+
+// error codes of throttling and timeout.
+var retryableCodes = map[string]struct{}{
+	"SlowDown":                  {},
+	"Throttling":                {},
+	"ThrottlingException":       {},
+	"RequestThrottled":          {},
+	"RequestLimitExceeded":      {},
+	"TooManyRequestsException":  {},
+	"RequestTimeout":            {},
+	"RequestTimeoutException":   {},
+	"ResponseTimeout":           {},
+	"InternalError":             {},
+	"ServiceUnavailable":        {},
+	"RequestThrottledException": {},
+}
+
+// IsRetryableError checks the error is transient error like throttling. (e.g. 503 SlowDown)
+// Other errors are not retried, like local file errors and checksum mismatch.
+func (c Client) IsRetryableError(err error) bool {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && provider.IsRetryableStatus(reqErr.StatusCode()) {
+		return true
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		if _, ok := retryableCodes[awsErr.Code()]; ok {
+			return true
+		}
+		// network error of the request. (e.g. 'RequestError')
+		if provider.IsNetworkError(awsErr.OrigErr()) {
+			return true
+		}
+	}
+	return provider.IsNetworkError(err)
+}
+
+// List lists objects from S3 Bucket.
+func (c Client) List(opt provider.ListOption) ([]provider.Object, error) {
+	b, err := c.S3.GetBucket(opt.BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := b.ListAllObjects(opt.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]provider.Object, 0, len(objects))
+	for _, o := range objects {
+		if strings.HasSuffix(o.Key, "/") {
+			continue
+		}
+		list = append(list, provider.Object{
+			Path: o.Key,
+			Size: o.Size,
+		})
+	}
+	return list, nil
+}
+
+// Download downloads from S3 Bucket to local file.
+func (c Client) Download(opt provider.FileOption) error {
+	out, err := c.sdk.GetObject(&SDK.GetObjectInput{
+		Bucket: aws.String(opt.BucketName),
+		Key:    aws.String(opt.SrcPath),
+	})
+	if err != nil {
+		return err
+	}
+	defer out.Body.Close() //nolint
+
+	return provider.WriteFile(opt.DstPath, out.Body)
+}
@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	SDK "github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestIsETagMD5(t *testing.T) {
+	const etag = `"900150983cd24fb0d6963f7d28e17f72"`
+	tests := []struct {
+		name     string
+		out      SDK.HeadObjectOutput
+		expected bool
+	}{
+		{"no encryption", SDK.HeadObjectOutput{ETag: aws.String(etag)}, true},
+		{"SSE-S3", SDK.HeadObjectOutput{ETag: aws.String(etag), ServerSideEncryption: aws.String("AES256")}, true},
+		{"SSE-KMS", SDK.HeadObjectOutput{ETag: aws.String(etag), ServerSideEncryption: aws.String("aws:kms")}, false},
+		{"SSE-C", SDK.HeadObjectOutput{ETag: aws.String(etag), SSECustomerAlgorithm: aws.String("AES256")}, false},
+		{"multipart", SDK.HeadObjectOutput{ETag: aws.String(`"900150983cd24fb0d6963f7d28e17f72-2"`)}, false},
+		{"no etag", SDK.HeadObjectOutput{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.out
+			if result := isETagMD5(&out); result != tt.expected {
+				t.Errorf("expected %v, but got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	_, openErr := os.Open("/path/to/none")
+	netErr := &url.Error{Op: "Put", URL: "https://example.com", Err: syscall.ECONNRESET}
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"503 SlowDown", awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), 503, "id"), true},
+		{"500", awserr.NewRequestFailure(awserr.New("InternalError", "error", nil), 500, "id"), true},
+		{"429", awserr.NewRequestFailure(awserr.New("TooManyRequests", "error", nil), 429, "id"), true},
+		{"wrapped 503", fmt.Errorf("upload: %w", awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "error", nil), 503, "id")), true},
+		{"404", awserr.NewRequestFailure(awserr.New("NoSuchKey", "not found", nil), 404, "id"), false},
+		{"403", awserr.NewRequestFailure(awserr.New("AccessDenied", "denied", nil), 403, "id"), false},
+		{"501", awserr.NewRequestFailure(awserr.New("NotImplemented", "error", nil), 501, "id"), false},
+		{"Throttling", awserr.New("Throttling", "rate exceeded", nil), true},
+		{"RequestTimeout", awserr.New("RequestTimeout", "timeout", nil), true},
+		{"RequestError with network error", awserr.New("RequestError", "send request failed", netErr), true},
+		{"RequestError with other error", awserr.New("RequestError", "send request failed", errors.New("invalid")), false},
+		{"network error", netErr, true},
+		{"NoSuchBucket", awserr.New("NoSuchBucket", "not found", nil), false},
+		{"local file", openErr, false},
+		{"checksum mismatch", errors.New("MD5 mismatch"), false},
+	}
+
+	c := Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := c.IsRetryableError(tt.err); result != tt.expected {
+				t.Errorf("expected %v, but got %v", tt.expected, result)
+			}
+		})
+	}
+}
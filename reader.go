@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// input file formats.
+const (
+	inputFormatCSV     = "csv"
+	inputFormatTSV     = "tsv"
+	inputFormatJSONL   = "jsonl"
+	inputFormatParquet = "parquet"
+)
+
+// getInputFormat returns input file format from the extension.
+func getInputFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".tsv":
+		return inputFormatTSV
+	case ".jsonl", ".ndjson":
+		return inputFormatJSONL
+	case ".parquet":
+		return inputFormatParquet
+	}
+	return inputFormatCSV
+}
+
+// rowReader reads rows from the input file.
+type rowReader interface {
+	// header returns column names.
+	header() []string
+	// read returns column name => value, and returns nil on EOF.
+	read() (map[string]string, error)
+	// close releases resources of the reader except the input file.
+	close() error
+}
+
+func newRowReader(fp *os.File, format string) (rowReader, error) {
+	switch strings.ToLower(format) {
+	case inputFormatCSV:
+		return newCSVRowReader(fp, ',')
+	case inputFormatTSV:
+		return newCSVRowReader(fp, '\t')
+	case inputFormatJSONL:
+		return newJSONLRowReader(fp)
+	case inputFormatParquet:
+		return newParquetRowReader(fp)
+	}
+	return nil, fmt.Errorf("unknown input format: [%s]", format)
+}
+
+// csvRowReader reads rows from CSV/TSV file with header line.
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVRowReader(r io.Reader, comma rune) (*csvRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.LazyQuotes = true
+	reader.Comma = comma
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &csvRowReader{
+		reader:  reader,
+		columns: header,
+	}, nil
+}
+
+func (r *csvRowReader) header() []string {
+	return r.columns
+}
+
+func (r *csvRowReader) read() (map[string]string, error) {
+	line, err := r.reader.Read()
+	switch {
+	case err == io.EOF:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for i, col := range line {
+		result[r.columns[i]] = col
+	}
+	return result, nil
+}
+
+func (r *csvRowReader) close() error {
+	return nil
+}
+
+// jsonlRowReader reads rows from JSON Lines file.
+// Nested objects are flattened with '.' (e.g. 'data.url'),
+// and arrays are kept as JSON string.
+// Columns are sorted keys of all rows, so the file is read twice to collect them.
+type jsonlRowReader struct {
+	reader  *bufio.Reader
+	columns []string
+}
+
+func newJSONLRowReader(r io.ReadSeeker) (*jsonlRowReader, error) {
+	jr := &jsonlRowReader{
+		reader: bufio.NewReader(r),
+	}
+
+	keys := make(map[string]struct{})
+	for {
+		row, err := jr.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			break
+		}
+		for col := range row {
+			keys[col] = struct{}{}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("empty JSON Lines file")
+	}
+
+	columns := make([]string, 0, len(keys))
+	for col := range keys {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	jr.reader.Reset(r)
+	jr.columns = columns
+	return jr, nil
+}
+
+func (r *jsonlRowReader) header() []string {
+	return r.columns
+}
+
+func (r *jsonlRowReader) read() (map[string]string, error) {
+	return r.readLine()
+}
+
+func (r *jsonlRowReader) close() error {
+	return nil
+}
+
+// readLine reads a JSON object from the next non-empty line.
+func (r *jsonlRowReader) readLine() (map[string]string, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return nil, nil
+			}
+			continue
+		}
+
+		var obj map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+		if err := d.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("invalid JSON line: %w", err)
+		}
+
+		result := make(map[string]string)
+		flattenJSON(result, "", obj)
+		return result, nil
+	}
+}
+
+// flattenJSON sets values of the JSON object into result with flattened keys.
+func flattenJSON(result map[string]string, prefix string, obj map[string]interface{}) {
+	for k, v := range obj {
+		key := prefix + k
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			flattenJSON(result, key+".", vv)
+		case nil:
+			result[key] = ""
+		case string:
+			result[key] = vv
+		case json.Number:
+			result[key] = vv.String()
+		case bool:
+			result[key] = fmt.Sprint(vv)
+		default:
+			byt, _ := json.Marshal(vv)
+			result[key] = string(byt)
+		}
+	}
+}
+
+// number of rows to read from Parquet file at once.
+const parquetBatchSize = 1000
+
+// parquetRowReader reads rows from Parquet file.
+// Nested columns are flattened with '.' (e.g. 'data.url'),
+// and values of repeated column are kept as JSON array string.
+type parquetRowReader struct {
+	reader  *reader.ParquetReader
+	columns []string
+	paths   []string
+
+	numRows  int64
+	readRows int64
+	buf      []map[string]string
+}
+
+func newParquetRowReader(fp *os.File) (*parquetRowReader, error) {
+	pr, err := reader.NewParquetColumnReader(parquetFile{File: fp}, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	sh := pr.SchemaHandler
+	columns := make([]string, 0, len(sh.ValueColumns))
+	for _, inPath := range sh.ValueColumns {
+		// remove root name. (e.g. 'parquet_go_root\x01data\x01url' => 'data.url')
+		exPath := strings.Split(sh.InPathToExPath[inPath], common.PAR_GO_PATH_DELIMITER)
+		columns = append(columns, strings.Join(exPath[1:], "."))
+	}
+
+	return &parquetRowReader{
+		reader:  pr,
+		columns: columns,
+		paths:   sh.ValueColumns,
+		numRows: pr.GetNumRows(),
+	}, nil
+}
+
+func (r *parquetRowReader) header() []string {
+	return r.columns
+}
+
+func (r *parquetRowReader) read() (map[string]string, error) {
+	if len(r.buf) == 0 {
+		if r.readRows >= r.numRows {
+			return nil, nil
+		}
+		if err := r.readBatch(); err != nil {
+			return nil, err
+		}
+	}
+
+	row := r.buf[0]
+	r.buf = r.buf[1:]
+	return row, nil
+}
+
+// close closes the files opened for each column.
+func (r *parquetRowReader) close() error {
+	r.reader.ReadStop()
+	return nil
+}
+
+func (r *parquetRowReader) readBatch() error {
+	num := r.numRows - r.readRows
+	if num > parquetBatchSize {
+		num = parquetBatchSize
+	}
+
+	rows := make([]map[string]string, num)
+	for i := range rows {
+		rows[i] = make(map[string]string, len(r.columns))
+	}
+	for i, path := range r.paths {
+		values, rls, _, err := r.reader.ReadColumnByPath(path, num)
+		if err != nil {
+			return err
+		}
+
+		// repetition level 0 means the start of a new row.
+		var rowValues [][]interface{}
+		for j, v := range values {
+			if rls[j] == 0 || len(rowValues) == 0 {
+				rowValues = append(rowValues, nil)
+			}
+			if v != nil {
+				rowValues[len(rowValues)-1] = append(rowValues[len(rowValues)-1], v)
+			}
+		}
+		for j := 0; j < len(rowValues) && j < len(rows); j++ {
+			rows[j][r.columns[i]] = formatParquetValues(rowValues[j])
+		}
+	}
+
+	r.readRows += num
+	r.buf = rows
+	return nil
+}
+
+func formatParquetValues(values []interface{}) string {
+	switch len(values) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprint(values[0])
+	}
+	byt, _ := json.Marshal(values)
+	return string(byt)
+}
+
+// parquetFile is read-only source.ParquetFile for local file.
+type parquetFile struct {
+	*os.File
+}
+
+func (f parquetFile) Open(name string) (source.ParquetFile, error) {
+	if name == "" {
+		name = f.Name()
+	}
+	fp, err := os.Open(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	return parquetFile{File: fp}, nil
+}
+
+func (f parquetFile) Create(name string) (source.ParquetFile, error) {
+	return nil, errors.New("parquet file is read only")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func readAllRows(t *testing.T, file, format string) ([]string, []map[string]string) {
+	t.Helper()
+
+	f, err := NewCSVHandlerWithFormat(file, format)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var rows []map[string]string
+	for {
+		line, err := f.Read()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(line) == 0 {
+			return f.header, rows
+		}
+		rows = append(rows, line)
+	}
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return file
+}
+
+func TestGetInputFormat(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"list.csv", inputFormatCSV},
+		{"list.tsv", inputFormatTSV},
+		{"list.jsonl", inputFormatJSONL},
+		{"list.NDJSON", inputFormatJSONL},
+		{"list.parquet", inputFormatParquet},
+		{"list", inputFormatCSV},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			result := getInputFormat(tt.file)
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCSVRowReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"list.csv", "id,label,url\n1,cat,http://example.com/1.jpg\n2,\"dog,puppy\",http://example.com/2.jpg\n"},
+		{"list.tsv", "id\tlabel\turl\n1\tcat\thttp://example.com/1.jpg\n2\tdog,puppy\thttp://example.com/2.jpg\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, rows := readAllRows(t, writeTestFile(t, tt.name, tt.content), "")
+			if expected := []string{"id", "label", "url"}; !reflect.DeepEqual(header, expected) {
+				t.Errorf("expected %v, but got %v", expected, header)
+			}
+			expected := []map[string]string{
+				{"id": "1", "label": "cat", "url": "http://example.com/1.jpg"},
+				{"id": "2", "label": "dog,puppy", "url": "http://example.com/2.jpg"},
+			}
+			if !reflect.DeepEqual(rows, expected) {
+				t.Errorf("expected %v, but got %v", expected, rows)
+			}
+		})
+	}
+}
+
+func TestJSONLRowReader(t *testing.T) {
+	content := `{"id": 1, "label": "cat", "data": {"url": "http://example.com/1.jpg"}}
+
+{"id": 2, "data": {"url": "http://example.com/2.jpg", "tags": ["a", "b"]}, "ok": true, "note": null}
+`
+	header, rows := readAllRows(t, writeTestFile(t, "list.jsonl", content), "")
+
+	// columns are the keys of all rows.
+	if expected := []string{"data.tags", "data.url", "id", "label", "note", "ok"}; !reflect.DeepEqual(header, expected) {
+		t.Errorf("expected %v, but got %v", expected, header)
+	}
+	expected := []map[string]string{
+		{"id": "1", "label": "cat", "data.url": "http://example.com/1.jpg"},
+		{"id": "2", "data.url": "http://example.com/2.jpg", "data.tags": `["a","b"]`, "ok": "true", "note": ""},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, but got %v", expected, rows)
+	}
+}
+
+func TestJSONLRowReaderError(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", "\n\n"},
+		{"invalid", "{\"id\": 1}\n{\"id\": \n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCSVHandler(writeTestFile(t, "list.jsonl", tt.content))
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+type testParquetRow struct {
+	ID    int64    `parquet:"name=id, type=INT64"`
+	Label string   `parquet:"name=label, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Tags  []string `parquet:"name=tags, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+}
+
+func TestParquetRowReader(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "list.parquet")
+	fp, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pw, err := writer.NewParquetWriterFromWriter(fp, new(testParquetRow), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// more rows than the batch size.
+	num := parquetBatchSize + 10
+	for i := 0; i < num; i++ {
+		row := testParquetRow{ID: int64(i), Label: "cat"}
+		if i == 1 {
+			row.Tags = []string{"a", "b"}
+		}
+		if err := pw.Write(row); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	header, rows := readAllRows(t, file, "")
+	if expected := []string{"id", "label", "tags.list.element"}; !reflect.DeepEqual(header, expected) {
+		t.Errorf("expected %v, but got %v", expected, header)
+	}
+	if len(rows) != num {
+		t.Fatalf("expected [%d] rows, but got [%d]", num, len(rows))
+	}
+	expected := []map[string]string{
+		{"id": "0", "label": "cat", "tags.list.element": ""},
+		{"id": "1", "label": "cat", "tags.list.element": `["a","b"]`},
+	}
+	if !reflect.DeepEqual(rows[:2], expected) {
+		t.Errorf("expected %v, but got %v", expected, rows[:2])
+	}
+	if last := rows[num-1]["id"]; last != "1009" {
+		t.Errorf("expected [1009], but got [%s]", last)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// uploadFailure is a failed file on upload.
+type uploadFailure struct {
+	Path       string
+	ObjectPath string
+	Error      string
+}
+
+// uploadResult counts results of the files and keeps failures.
+type uploadResult struct {
+	uploaded uint64
+	skipped  uint64
+	deleted  uint64
+
+	failuresMu sync.Mutex
+	failures   []uploadFailure
+}
+
+func (r *uploadResult) addUploaded() {
+	atomic.AddUint64(&r.uploaded, 1)
+}
+
+func (r *uploadResult) addSkipped() {
+	atomic.AddUint64(&r.skipped, 1)
+}
+
+func (r *uploadResult) addDeleted() {
+	atomic.AddUint64(&r.deleted, 1)
+}
+
+func (r *uploadResult) addFailure(path, objectPath string, err error) {
+	r.failuresMu.Lock()
+	defer r.failuresMu.Unlock()
+	r.failures = append(r.failures, uploadFailure{
+		Path:       path,
+		ObjectPath: objectPath,
+		Error:      err.Error(),
+	})
+}
+
+func (r *uploadResult) failedCount() int {
+	r.failuresMu.Lock()
+	defer r.failuresMu.Unlock()
+	return len(r.failures)
+}
+
+func (r *uploadResult) String() string {
+	return fmt.Sprintf("[RESULT] uploaded=[%d] skipped=[%d] deleted=[%d] failed=[%d]",
+		atomic.LoadUint64(&r.uploaded),
+		atomic.LoadUint64(&r.skipped),
+		atomic.LoadUint64(&r.deleted),
+		r.failedCount(),
+	)
+}
+
+// WriteFailures writes failed files into CSV file, which can be used for --from-failed.
+func (r *uploadResult) WriteFailures(file string) error {
+	r.failuresMu.Lock()
+	defer r.failuresMu.Unlock()
+
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"path", "object_path", "error"})
+	for _, f := range r.failures {
+		_ = w.Write([]string{f.Path, f.ObjectPath, f.Error})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return provider.WriteFile(file, buf)
+}
+
+// readFailedFiles reads local file paths from the failure report.
+func readFailedFiles(file string) ([]string, error) {
+	f, err := NewCSVHandler(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	if err := f.checkHeaders("path"); err != nil {
+		return nil, err
+	}
+
+	var list []string
+	for {
+		line, err := f.Read()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return list, nil
+		}
+
+		// failures of deletion do not have local path.
+		if line["path"] == "" {
+			continue
+		}
+		list = append(list, line["path"])
+	}
+}
+
+// rowReport keeps rows of the input file with the additional column. (e.g. rejected rows with the reason on download)
+type rowReport struct {
+	header []string
+	column string
+
+	rowsMu sync.Mutex
+	rows   [][]string
+}
+
+func newRowReport(header []string, column string) *rowReport {
+	return &rowReport{
+		header: header,
+		column: column,
+	}
+}
+
+func (r *rowReport) add(line map[string]string, value string) {
+	row := make([]string, 0, len(r.header)+1)
+	for _, col := range r.header {
+		row = append(row, line[col])
+	}
+	row = append(row, value)
+
+	r.rowsMu.Lock()
+	defer r.rowsMu.Unlock()
+	r.rows = append(r.rows, row)
+}
+
+// WriteFile writes rows into CSV file with the additional column.
+func (r *rowReport) WriteFile(file string) error {
+	r.rowsMu.Lock()
+	defer r.rowsMu.Unlock()
+
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.Write(append(append([]string{}, r.header...), r.column))
+	_ = w.WriteAll(r.rows)
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return provider.WriteFile(file, buf)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"time"
+)
+
+// RetryOption is command line options for retry.
+type RetryOption struct {
+	RetryMax     int      `cli:"retry" usage:"max retry count for transient errors (e.g. throttling) --retry=3" dft:"3"`
+	RetryWait    duration `cli:"retry-wait" usage:"initial wait time of exponential backoff --retry-wait=1s" dft:"1s"`
+	RetryMaxWait duration `cli:"retry-max-wait" usage:"max wait time of exponential backoff --retry-max-wait=30s" dft:"30s"`
+	RetryJitter  float64  `cli:"retry-jitter" usage:"ratio of random jitter for wait time (0.0 - 1.0) --retry-jitter=0.5" dft:"0.5"`
+}
+
+// retryPolicy retries a function with exponential backoff.
+type retryPolicy struct {
+	MaxRetry int
+	Wait     time.Duration
+	MaxWait  time.Duration
+	Jitter   float64
+}
+
+func newRetryPolicy(p RetryOption) retryPolicy {
+	return retryPolicy{
+		MaxRetry: p.RetryMax,
+		Wait:     p.RetryWait.Duration,
+		MaxWait:  p.RetryMaxWait.Duration,
+		Jitter:   p.RetryJitter,
+	}
+}
+
+// Do executes fn and retries it while the error is retryable.
+func (p retryPolicy) Do(fn func() error, isRetryable func(error) bool) error {
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= p.MaxRetry || !isRetryable(err) {
+			return err
+		}
+
+		wait := p.getWait(i)
+		fmt.Printf("[RETRY] #=[%d] wait=[%s] error=[%s]\n", i+1, wait, err.Error())
+		time.Sleep(wait)
+	}
+}
+
+// getWait returns wait time for the retry count.
+// (e.g.) Wait=1s, Jitter=0.5 => 0.5s-1s, 1s-2s, 2s-4s, ...
+func (p retryPolicy) getWait(count int) time.Duration {
+	if p.Wait <= 0 {
+		return 0
+	}
+
+	// cap the exponent to avoid overflow of the shift.
+	if maxShift := bits.LeadingZeros64(uint64(p.Wait)) - 1; count > maxShift {
+		count = maxShift
+	}
+	wait := p.Wait << uint(count)
+	if p.MaxWait > 0 && wait > p.MaxWait {
+		wait = p.MaxWait
+	}
+
+	jitter := p.Jitter
+	switch {
+	case jitter <= 0:
+		return wait
+	case jitter > 1:
+		jitter = 1
+	}
+	return wait - time.Duration(float64(wait)*jitter*rand.Float64()) //nolint:gosec
+}
+
+// duration is time.Duration for command line options. (e.g. '500ms', '30s')
+type duration struct {
+	time.Duration
+}
+
+// Decode implements cli.Decoder.
+func (d *duration) Decode(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
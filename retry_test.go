@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyGetWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   retryPolicy
+		expected []time.Duration
+	}{
+		{
+			name:     "exponential",
+			policy:   retryPolicy{Wait: time.Second, MaxWait: 30 * time.Second},
+			expected: []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second},
+		},
+		{
+			name:     "zero wait",
+			policy:   retryPolicy{Wait: 0, MaxWait: 30 * time.Second},
+			expected: []time.Duration{0, 0, 0},
+		},
+		{
+			name:     "no max wait",
+			policy:   retryPolicy{Wait: 100 * time.Millisecond},
+			expected: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, expected := range tt.expected {
+				if result := tt.policy.getWait(i); result != expected {
+					t.Errorf("count=[%d]: expected [%s], but got [%s]", i, expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyGetWaitOverflow(t *testing.T) {
+	p := retryPolicy{Wait: 10 * time.Second, MaxWait: time.Minute}
+	for _, count := range []int{30, 62, 63, 64, 1000} {
+		if result := p.getWait(count); result != time.Minute {
+			t.Errorf("count=[%d]: expected [%s], but got [%s]", count, time.Minute, result)
+		}
+	}
+
+	// without max wait, the wait never becomes negative.
+	p = retryPolicy{Wait: 10 * time.Second}
+	for _, count := range []int{30, 62, 63, 64, 1000} {
+		if result := p.getWait(count); result <= 0 {
+			t.Errorf("count=[%d]: expected positive wait, but got [%s]", count, result)
+		}
+	}
+}
+
+func TestRetryPolicyGetWaitJitter(t *testing.T) {
+	p := retryPolicy{Wait: time.Second, MaxWait: 30 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		result := p.getWait(1)
+		if result < time.Second || result > 2*time.Second {
+			t.Fatalf("expected 1s-2s, but got [%s]", result)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	errRetryable := errors.New("retryable")
+	errFatal := errors.New("fatal")
+	isRetryable := func(err error) bool { return errors.Is(err, errRetryable) }
+
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"retry and success", []error{errRetryable, errRetryable, nil}, 3, nil},
+		{"max retry", []error{errRetryable, errRetryable, errRetryable, errRetryable, nil}, 4, errRetryable},
+		{"not retryable", []error{errFatal, nil}, 1, errFatal},
+	}
+
+	p := retryPolicy{MaxRetry: 3}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := p.Do(func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			}, isRetryable)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected error [%v], but got [%v]", tt.expectedErr, err)
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("expected %d calls, but got %d", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
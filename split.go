@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dataset split names of ML_USE.
+const (
+	splitTrain      = "TRAIN"
+	splitValidation = "VALIDATION"
+	splitTest       = "TEST"
+	splitUnassigned = "UNASSIGNED"
+)
+
+// split methods.
+const (
+	splitByHash       = "hash"
+	splitByStratified = "stratified"
+	splitByDir        = "dir"
+)
+
+// dir names for split by dir convention. (e.g. 'train/cat/1.jpg')
+var splitDirNames = map[string]string{
+	"train":      splitTrain,
+	"training":   splitTrain,
+	"val":        splitValidation,
+	"valid":      splitValidation,
+	"validation": splitValidation,
+	"test":       splitTest,
+	"testing":    splitTest,
+}
+
+// file names for --split-files.
+var splitFileNames = map[string]string{
+	splitTrain:      "train",
+	splitValidation: "val",
+	splitTest:       "test",
+}
+
+// SplitOption is command line options for dataset split.
+type SplitOption struct {
+	Split           string  `cli:"split" usage:"split method, 'hash' (by hash of file path), 'stratified' (by ratios per label) or 'dir' (by dir name like 'train/cat/1.jpg') --split=hash" dft:"hash"`
+	TrainRatio      float64 `cli:"train-ratio" usage:"ratio of TRAIN split (0.0 - 1.0) --train-ratio=0.8" dft:"0"`
+	ValidationRatio float64 `cli:"validation-ratio" usage:"ratio of VALIDATION split (0.0 - 1.0) --validation-ratio=0.1" dft:"0"`
+	TestRatio       float64 `cli:"test-ratio" usage:"ratio of TEST split (0.0 - 1.0) --test-ratio=0.1" dft:"0"`
+	SplitSeed       string  `cli:"split-seed" usage:"seed for hash based split, change it to shuffle splits --split-seed='v1'"`
+	SplitFiles      bool    `cli:"split-files" usage:"write train, val and test files into the dir of --output in addition (e.g. train.csv, val.csv, test.csv)"`
+}
+
+// splitItem is an item to assign split.
+type splitItem struct {
+	// key is stable key for hash. (e.g. relative file path)
+	key string
+	// label is used for stratified split.
+	label string
+}
+
+// datasetSplitter assigns split for the items, and the rest of the ratios is UNASSIGNED.
+// Hash split is stable, so that reruns keep each file in the same split even if files are added.
+// Stratified split is the same on reruns with the same files, but adding files may move other files.
+type datasetSplitter struct {
+	method     string
+	train      float64
+	validation float64
+	test       float64
+	seed       string
+}
+
+func newDatasetSplitter(opt SplitOption) (datasetSplitter, error) {
+	switch opt.Split {
+	case "", splitByHash, splitByStratified, splitByDir:
+	default:
+		return datasetSplitter{}, fmt.Errorf("unknown split method: [%s]", opt.Split)
+	}
+	for _, v := range []float64{opt.TrainRatio, opt.ValidationRatio, opt.TestRatio} {
+		if v < 0 || v > 1 {
+			return datasetSplitter{}, fmt.Errorf("split ratio must be 0.0 - 1.0: [%v]", v)
+		}
+	}
+	// allow rounding error. (e.g. 0.7 + 0.2 + 0.1)
+	if opt.TrainRatio+opt.ValidationRatio+opt.TestRatio > 1+1e-9 {
+		return datasetSplitter{}, fmt.Errorf("sum of split ratios must be <= 1.0")
+	}
+	return datasetSplitter{
+		method:     opt.Split,
+		train:      opt.TrainRatio,
+		validation: opt.ValidationRatio,
+		test:       opt.TestRatio,
+		seed:       opt.SplitSeed,
+	}, nil
+}
+
+func (s datasetSplitter) isDirMethod() bool {
+	return s.method == splitByDir
+}
+
+// assign returns splits of the items in the same order.
+func (s datasetSplitter) assign(items []splitItem) []string {
+	splits := make([]string, len(items))
+	switch s.method {
+	case splitByDir:
+		for i, item := range items {
+			splits[i], _ = splitFromDir(item.key)
+		}
+	case splitByStratified:
+		s.assignStratified(items, splits)
+	default:
+		for i, item := range items {
+			splits[i] = s.assignByRatio(hashRatio(s.seed, item.key))
+		}
+	}
+	return splits
+}
+
+// assignStratified sorts items by hash per label, and assigns splits by the count of the ratios.
+// The split of an item depends on the rank in the label, so it is not stable when files are added.
+func (s datasetSplitter) assignStratified(items []splitItem, splits []string) {
+	groups := make(map[string][]int)
+	ratios := make([]float64, len(items))
+	for i, item := range items {
+		groups[item.label] = append(groups[item.label], i)
+		ratios[i] = hashRatio(s.seed, item.key)
+	}
+
+	for _, indexes := range groups {
+		sort.Slice(indexes, func(i, j int) bool {
+			return ratios[indexes[i]] < ratios[indexes[j]]
+		})
+		n := float64(len(indexes))
+		for rank, idx := range indexes {
+			// use the center of the rank to round the count of each split.
+			splits[idx] = s.assignByRatio((float64(rank) + 0.5) / n)
+		}
+	}
+}
+
+func (s datasetSplitter) assignByRatio(v float64) string {
+	switch {
+	case v < s.train:
+		return splitTrain
+	case v < s.train+s.validation:
+		return splitValidation
+	case v < s.train+s.validation+s.test:
+		return splitTest
+	}
+	return splitUnassigned
+}
+
+// hashRatio returns stable value in [0.0, 1.0) from hash of the seed and the key.
+func hashRatio(seed, key string) float64 {
+	h := sha1.Sum([]byte(seed + "\x00" + key)) //nolint:gosec
+	return float64(binary.BigEndian.Uint64(h[:8])) / (math.MaxUint64 + 1.0)
+}
+
+// splitFromDir returns split from the first dir name and the rest of the path.
+// (e.g.) 'train/cat/1.jpg' => 'TRAIN', 'cat/1.jpg'
+func splitFromDir(path string) (split, rest string) {
+	path = filepath.ToSlash(path)
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 {
+		return splitUnassigned, path
+	}
+	if split, ok := splitDirNames[strings.ToLower(parts[0])]; ok {
+		return split, parts[1]
+	}
+	return splitUnassigned, path
+}
+
+// trimSplitDir removes split dir from the label. (e.g. 'train/cat' => 'cat')
+func trimSplitDir(label string) string {
+	_, rest := splitFromDir(label + "/")
+	return strings.TrimSuffix(rest, "/")
+}
+
+// getSplitFilePath returns file path for the split in the dir of the output file.
+// (e.g.) output='./data/list.csv', split='VALIDATION' => './data/val.csv'
+func getSplitFilePath(output, split string) string {
+	return filepath.Join(filepath.Dir(output), splitFileNames[split]+filepath.Ext(output))
+}
+
+// writeRecords writes records into the output file, and into the files of each split by --split-files.
+// UNASSIGNED records are not written into the split files.
+func writeRecords(w recordWriter, output string, records []labelRecord, splitFiles bool) (err error) {
+	files := []string{output}
+	splits := []string{""}
+	if splitFiles {
+		for _, split := range []string{splitTrain, splitValidation, splitTest} {
+			files = append(files, getSplitFilePath(output, split))
+			splits = append(splits, split)
+		}
+	}
+
+	// remaining streams are removed on error.
+	var streams []recordStream
+	defer func() {
+		if err != nil {
+			for _, s := range streams {
+				s.abort()
+			}
+		}
+	}()
+	bySplit := make(map[string]recordStream)
+	for i, file := range files {
+		s, err := w.begin(file)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, s)
+		if splits[i] != "" {
+			bySplit[splits[i]] = s
+		}
+	}
+
+	for _, rec := range records {
+		if err := streams[0].write(rec); err != nil {
+			return err
+		}
+		if s, ok := bySplit[rec.split]; ok {
+			if err := s.write(rec); err != nil {
+				return err
+			}
+		}
+	}
+
+	for len(streams) != 0 {
+		s := streams[0]
+		streams = streams[1:]
+		if err := s.end(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
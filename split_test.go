@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func newTestSplitItems(labels []string, num int) []splitItem {
+	items := make([]splitItem, 0, len(labels)*num)
+	for _, label := range labels {
+		for i := 0; i < num; i++ {
+			items = append(items, splitItem{
+				key:   fmt.Sprintf("%s/%d.jpg", label, i),
+				label: label,
+			})
+		}
+	}
+	return items
+}
+
+func countSplits(splits []string) map[string]int {
+	counts := make(map[string]int)
+	for _, s := range splits {
+		counts[s]++
+	}
+	return counts
+}
+
+func TestNewDatasetSplitter(t *testing.T) {
+	tests := []struct {
+		name  string
+		opt   SplitOption
+		isErr bool
+	}{
+		{"default", SplitOption{}, false},
+		{"ratios", SplitOption{Split: splitByStratified, TrainRatio: 0.7, ValidationRatio: 0.2, TestRatio: 0.1}, false},
+		{"unknown method", SplitOption{Split: "random"}, true},
+		{"negative ratio", SplitOption{TrainRatio: -0.1}, true},
+		{"sum over 1", SplitOption{TrainRatio: 0.8, ValidationRatio: 0.3}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newDatasetSplitter(tt.opt)
+			if tt.isErr != (err != nil) {
+				t.Errorf("expected error=[%t], but got [%v]", tt.isErr, err)
+			}
+		})
+	}
+}
+
+func TestDatasetSplitterRatio(t *testing.T) {
+	items := newTestSplitItems([]string{"cat", "dog", "bird"}, 1000)
+	tests := []struct {
+		method    string
+		tolerance float64
+	}{
+		{splitByHash, 0.03},
+		{splitByStratified, 0.001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			s, err := newDatasetSplitter(SplitOption{Split: tt.method, TrainRatio: 0.7, ValidationRatio: 0.2})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			counts := countSplits(s.assign(items))
+			expected := map[string]float64{
+				splitTrain:      0.7,
+				splitValidation: 0.2,
+				splitTest:       0,
+				splitUnassigned: 0.1,
+			}
+			for split, ratio := range expected {
+				v := float64(counts[split]) / float64(len(items))
+				if math.Abs(v-ratio) > tt.tolerance {
+					t.Errorf("expected ratio of [%s] is [%v], but got [%v]", split, ratio, v)
+				}
+			}
+		})
+	}
+}
+
+func TestDatasetSplitterStratifiedPerLabel(t *testing.T) {
+	// labels with few files have the exact count of each split.
+	items := newTestSplitItems([]string{"cat", "dog"}, 10)
+	s, err := newDatasetSplitter(SplitOption{Split: splitByStratified, TrainRatio: 0.8, ValidationRatio: 0.1, TestRatio: 0.1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	splits := s.assign(items)
+	for i, label := range []string{"cat", "dog"} {
+		counts := countSplits(splits[i*10 : (i+1)*10])
+		expected := map[string]int{splitTrain: 8, splitValidation: 1, splitTest: 1}
+		for split, num := range expected {
+			if counts[split] != num {
+				t.Errorf("expected [%d] of [%s] in [%s], but got [%d]", num, split, label, counts[split])
+			}
+		}
+	}
+}
+
+func TestDatasetSplitterStability(t *testing.T) {
+	items := newTestSplitItems([]string{"cat", "dog"}, 500)
+	added := append(newTestSplitItems([]string{"cat", "dog"}, 600), splitItem{key: "bird/1.jpg", label: "bird"})
+
+	for _, method := range []string{splitByHash, splitByStratified} {
+		t.Run(method, func(t *testing.T) {
+			s, err := newDatasetSplitter(SplitOption{Split: method, TrainRatio: 0.8, ValidationRatio: 0.2, SplitSeed: "v1"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			before := s.assign(items)
+
+			// the same items are assigned to the same splits in any order.
+			reversed := make([]splitItem, len(items))
+			for i, item := range items {
+				reversed[len(items)-1-i] = item
+			}
+			after := s.assign(reversed)
+			for i := range items {
+				if before[i] != after[len(items)-1-i] {
+					t.Fatalf("expected [%s] for [%s], but got [%s]", before[i], items[i].key, after[len(items)-1-i])
+				}
+			}
+
+			if method != splitByHash {
+				return
+			}
+			// hash split keeps the splits when files are added.
+			after = s.assign(added)
+			index := make(map[string]string, len(added))
+			for i, item := range added {
+				index[item.key] = after[i]
+			}
+			for i, item := range items {
+				if index[item.key] != before[i] {
+					t.Fatalf("expected [%s] for [%s], but got [%s]", before[i], item.key, index[item.key])
+				}
+			}
+		})
+	}
+}
+
+func TestDatasetSplitterSeed(t *testing.T) {
+	items := newTestSplitItems([]string{"cat"}, 100)
+	s1, _ := newDatasetSplitter(SplitOption{TrainRatio: 0.5, SplitSeed: "v1"})
+	s2, _ := newDatasetSplitter(SplitOption{TrainRatio: 0.5, SplitSeed: "v2"})
+
+	splits1 := s1.assign(items)
+	splits2 := s2.assign(items)
+	diff := 0
+	for i := range items {
+		if splits1[i] != splits2[i] {
+			diff++
+		}
+	}
+	if diff == 0 {
+		t.Errorf("expected different splits by the seed")
+	}
+}
+
+func TestSplitFromDir(t *testing.T) {
+	tests := []struct {
+		path  string
+		split string
+		rest  string
+	}{
+		{"train/cat/1.jpg", splitTrain, "cat/1.jpg"},
+		{"Validation/cat/1.jpg", splitValidation, "cat/1.jpg"},
+		{"val/1.jpg", splitValidation, "1.jpg"},
+		{"testing/dog/2.jpg", splitTest, "dog/2.jpg"},
+		{"cat/1.jpg", splitUnassigned, "cat/1.jpg"},
+		{"1.jpg", splitUnassigned, "1.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			split, rest := splitFromDir(tt.path)
+			if split != tt.split || rest != tt.rest {
+				t.Errorf("expected [%s, %s], but got [%s, %s]", tt.split, tt.rest, split, rest)
+			}
+		})
+	}
+
+	if label := trimSplitDir("train/cat"); label != "cat" {
+		t.Errorf("expected [cat], but got [%s]", label)
+	}
+}
+
+func TestHashRatio(t *testing.T) {
+	v := hashRatio("v1", "cat/1.jpg")
+	if v < 0 || v >= 1 {
+		t.Errorf("expected [0.0, 1.0), but got [%v]", v)
+	}
+	if v != hashRatio("v1", "cat/1.jpg") {
+		t.Errorf("expected the same value for the same key")
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/evalphobia/cloud-label-uploader/provider"
+)
+
+// recordWriter begins the output file to write records one by one,
+// so that whole-file formats (e.g. COCO JSON) do not hold all records in memory.
+// It is created once and used for all output files. (e.g. --split-files)
+type recordWriter interface {
+	begin(file string) (recordStream, error)
+}
+
+// recordStream writes records into the output file.
+// end writes the footer and saves the file, and abort removes the file being written on error.
+type recordStream interface {
+	write(rec labelRecord) error
+	end() error
+	abort()
+}
+
+// lineFile writes lines into the temporary file, and renames it to the path on commit.
+// Lines are joined by '\n' without the last newline.
+type lineFile struct {
+	file    *provider.AtomicFile
+	w       *bufio.Writer
+	hasLine bool
+}
+
+func newLineFile(file string) (*lineFile, error) {
+	if _, err := NewFileHandler(file); err != nil {
+		return nil, err
+	}
+	f, err := provider.CreateFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return &lineFile{
+		file: f,
+		w:    bufio.NewWriter(f),
+	}, nil
+}
+
+func (f *lineFile) writeLine(line string) error {
+	if f.hasLine {
+		if err := f.w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	f.hasLine = true
+	_, err := f.w.WriteString(line)
+	return err
+}
+
+func (f *lineFile) commit() error {
+	if err := f.w.Flush(); err != nil {
+		f.file.Abort()
+		return err
+	}
+	return f.file.Commit()
+}
+
+func (f *lineFile) abort() {
+	f.file.Abort()
+}
+
+// lineWriter writes lines of the formatter. (e.g. CSV, JSONL)
+type lineWriter struct {
+	formatter formatter
+}
+
+func newLineWriter(f formatter) *lineWriter {
+	return &lineWriter{
+		formatter: f,
+	}
+}
+
+func (w *lineWriter) begin(file string) (recordStream, error) {
+	f, err := newLineFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return &lineStream{
+		formatter: w.formatter,
+		lineFile:  f,
+	}, nil
+}
+
+type lineStream struct {
+	formatter formatter
+	*lineFile
+}
+
+func (s *lineStream) write(rec labelRecord) error {
+	lines, err := s.formatter.format(rec)
+	if err != nil {
+		return fmt.Errorf("failed to format: [%s], %w", rec.path, err)
+	}
+	for _, line := range lines {
+		if err := s.writeLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *lineStream) end() error {
+	return s.commit()
+}
+
+// cocoWriter writes COCO JSON file.
+// Images are written into the output file and annotations are written into the temporary file,
+// and they are joined with categories on the end.
+type cocoWriter struct{}
+
+type cocoImage struct {
+	ID       int    `json:"id"`
+	FileName string `json:"file_name"`
+	Width    int64  `json:"width"`
+	Height   int64  `json:"height"`
+}
+
+type cocoAnnotation struct {
+	ID           int         `json:"id"`
+	ImageID      int         `json:"image_id"`
+	CategoryID   int         `json:"category_id"`
+	BBox         []float64   `json:"bbox"`
+	Area         float64     `json:"area"`
+	Segmentation [][]float64 `json:"segmentation,omitempty"`
+	IsCrowd      int         `json:"iscrowd"`
+}
+
+type cocoCategory struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (w *cocoWriter) begin(file string) (recordStream, error) {
+	images, err := newLineFile(file)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := provider.CreateFile(file + ".annotations")
+	if err != nil {
+		images.abort()
+		return nil, err
+	}
+	if _, err := images.w.WriteString("{\n  \"images\": ["); err != nil {
+		images.abort()
+		annotations.Abort()
+		return nil, err
+	}
+	return &cocoStream{
+		images:      images,
+		annotations: annotations,
+		annW:        bufio.NewWriter(annotations),
+		categoryIDs: make(map[string]int),
+	}, nil
+}
+
+type cocoStream struct {
+	images      *lineFile
+	annotations *provider.AtomicFile
+	annW        *bufio.Writer
+
+	numImages      int
+	numAnnotations int
+	// category id starts from 1 in order of appearance.
+	categoryIDs map[string]int
+	categories  []cocoCategory
+}
+
+func (s *cocoStream) write(rec labelRecord) error {
+	s.numImages++
+	imageID := s.numImages
+	image, err := getJSONElement(imageID == 1, cocoImage{
+		ID:       imageID,
+		FileName: rec.path,
+		Width:    rec.metadata.width,
+		Height:   rec.metadata.height,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to format: [%s], %w", rec.path, err)
+	}
+	if _, err := s.images.w.WriteString(image); err != nil {
+		return err
+	}
+
+	width := float64(rec.metadata.width)
+	height := float64(rec.metadata.height)
+	for _, b := range rec.boxes {
+		// bbox: [x, y, width, height] in pixels
+		bw := (b.xMax - b.xMin) * width
+		bh := (b.yMax - b.yMin) * height
+		s.numAnnotations++
+		a := cocoAnnotation{
+			ID:         s.numAnnotations,
+			ImageID:    imageID,
+			CategoryID: s.getCategoryID(b.label),
+			BBox:       []float64{b.xMin * width, b.yMin * height, bw, bh},
+			Area:       bw * bh,
+		}
+		if len(b.polygon) != 0 {
+			seg := make([]float64, 0, len(b.polygon)*2)
+			for _, p := range b.polygon {
+				seg = append(seg, p.x*width, p.y*height)
+			}
+			a.Segmentation = [][]float64{seg}
+		}
+		annotation, err := getJSONElement(a.ID == 1, a)
+		if err != nil {
+			return fmt.Errorf("failed to format: [%s], %w", rec.path, err)
+		}
+		if _, err := s.annW.WriteString(annotation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *cocoStream) getCategoryID(name string) int {
+	if id, ok := s.categoryIDs[name]; ok {
+		return id
+	}
+	id := len(s.categoryIDs) + 1
+	s.categoryIDs[name] = id
+	s.categories = append(s.categories, cocoCategory{
+		ID:   id,
+		Name: name,
+	})
+	return id
+}
+
+func (s *cocoStream) end() error {
+	defer s.annotations.Abort()
+
+	err := s.annW.Flush()
+	if err == nil {
+		_, err = s.annotations.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		_, err = s.images.w.WriteString("\n  ],\n  \"annotations\": [")
+	}
+	if err == nil {
+		_, err = io.Copy(s.images.w, s.annotations)
+	}
+	if err == nil {
+		_, err = s.images.w.WriteString("\n  ],\n  \"categories\": [")
+	}
+	for i, c := range s.categories {
+		var category string
+		if err == nil {
+			category, err = getJSONElement(i == 0, c)
+		}
+		if err == nil {
+			_, err = s.images.w.WriteString(category)
+		}
+	}
+	if err == nil {
+		_, err = s.images.w.WriteString("\n  ]\n}\n")
+	}
+	if err != nil {
+		s.images.abort()
+		return err
+	}
+	return s.images.commit()
+}
+
+func (s *cocoStream) abort() {
+	s.images.abort()
+	s.annotations.Abort()
+}
+
+// getJSONElement returns JSON of the array element on a new line, with ',' for the previous element except the first one.
+func getJSONElement(isFirst bool, v interface{}) (string, error) {
+	byt, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if isFirst {
+		return "\n    " + string(byt), nil
+	}
+	return ",\n    " + string(byt), nil
+}
+
+// vocWriter writes Pascal VOC XML files of the images into 'Annotations' dir next to the output file,
+// and the output file has the image ids like 'ImageSets/Main/train.txt'.
+// Image ids are shared between the output files, so that the XML file of the image is written only once.
+type vocWriter struct {
+	ids   map[string]string // image id => name
+	names map[string]string // name => image id
+}
+
+func newVOCWriter() *vocWriter {
+	return &vocWriter{
+		ids:   make(map[string]string),
+		names: make(map[string]string),
+	}
+}
+
+type vocAnnotation struct {
+	XMLName   xml.Name    `xml:"annotation"`
+	Folder    string      `xml:"folder"`
+	Filename  string      `xml:"filename"`
+	Path      string      `xml:"path"`
+	Size      vocSize     `xml:"size"`
+	Segmented int         `xml:"segmented"`
+	Objects   []vocObject `xml:"object"`
+}
+
+type vocSize struct {
+	Width  int64 `xml:"width"`
+	Height int64 `xml:"height"`
+	Depth  int   `xml:"depth"`
+}
+
+type vocObject struct {
+	Name      string    `xml:"name"`
+	Pose      string    `xml:"pose"`
+	Truncated int       `xml:"truncated"`
+	Difficult int       `xml:"difficult"`
+	BndBox    vocBndBox `xml:"bndbox"`
+}
+
+type vocBndBox struct {
+	XMin int64 `xml:"xmin"`
+	YMin int64 `xml:"ymin"`
+	XMax int64 `xml:"xmax"`
+	YMax int64 `xml:"ymax"`
+}
+
+func (w *vocWriter) begin(file string) (recordStream, error) {
+	annotationDir := filepath.Join(filepath.Dir(file), "Annotations")
+	if err := makeDir(annotationDir); err != nil {
+		return nil, err
+	}
+
+	f, err := newLineFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return &vocStream{
+		writer:        w,
+		annotationDir: annotationDir,
+		lineFile:      f,
+	}, nil
+}
+
+// getImageID returns image id of the name, and isNew=false when the id is already used for the name.
+// Hash of the name is added to the id when other name has the same id. (e.g. 'cat/1.jpg' and 'cat/1.png')
+func (w *vocWriter) getImageID(name string) (id string, isNew bool, err error) {
+	if id, ok := w.names[name]; ok {
+		return id, false, nil
+	}
+
+	id = getVOCImageID(name)
+	if _, ok := w.ids[id]; ok {
+		id += "_" + getURLHash(name)[:8]
+	}
+	if other, ok := w.ids[id]; ok {
+		return "", false, fmt.Errorf("duplicate VOC image id: [%s], name=[%s], other=[%s]", id, name, other)
+	}
+	w.ids[id] = name
+	w.names[name] = id
+	return id, true, nil
+}
+
+type vocStream struct {
+	writer        *vocWriter
+	annotationDir string
+	*lineFile
+}
+
+func (s *vocStream) write(rec labelRecord) error {
+	id, isNew, err := s.writer.getImageID(rec.name)
+	if err != nil {
+		return err
+	}
+	if isNew {
+		byt, err := xml.MarshalIndent(newVOCAnnotation(rec), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := provider.WriteFile(filepath.Join(s.annotationDir, id+".xml"), bytes.NewReader(byt)); err != nil {
+			return err
+		}
+	}
+	return s.writeLine(id)
+}
+
+func (s *vocStream) end() error {
+	return s.commit()
+}
+
+func newVOCAnnotation(rec labelRecord) vocAnnotation {
+	dir, fileName := path.Split(filepath.ToSlash(rec.name))
+	a := vocAnnotation{
+		Folder:   strings.TrimSuffix(dir, "/"),
+		Filename: fileName,
+		Path:     rec.path,
+		Size: vocSize{
+			Width:  rec.metadata.width,
+			Height: rec.metadata.height,
+			Depth:  3,
+		},
+	}
+
+	width := float64(rec.metadata.width)
+	height := float64(rec.metadata.height)
+	for _, b := range rec.boxes {
+		a.Objects = append(a.Objects, vocObject{
+			Name: b.label,
+			Pose: "Unspecified",
+			BndBox: vocBndBox{
+				XMin: int64(math.Round(b.xMin * width)),
+				YMin: int64(math.Round(b.yMin * height)),
+				XMax: int64(math.Round(b.xMax * width)),
+				YMax: int64(math.Round(b.yMax * height)),
+			},
+		})
+	}
+	return a
+}
+
+// getVOCImageID returns image id from the relative path without extension. (e.g. 'cat/1.jpg' => 'cat_1')
+func getVOCImageID(name string) string {
+	name = strings.TrimSuffix(filepath.ToSlash(name), filepath.Ext(name))
+	return strings.ReplaceAll(name, "/", "_")
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readTestFile(t *testing.T, file string) string {
+	t.Helper()
+
+	byt, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(byt)
+}
+
+func listTestDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	return names
+}
+
+func newTestDetectionRecords() []labelRecord {
+	return []labelRecord{
+		{
+			path:     "gs://bucket/cat/1.jpg",
+			name:     "cat/1.jpg",
+			labels:   []string{"cat"},
+			split:    splitTrain,
+			metadata: imageMetadata{width: 200, height: 100},
+			boxes: []boundingBox{
+				{label: "cat", xMin: 0.1, yMin: 0.2, xMax: 0.5, yMax: 0.6},
+				{label: "dog", xMin: 0, yMin: 0, xMax: 1, yMax: 1, polygon: []point{{0, 0}, {1, 0}, {1, 1}}},
+			},
+		},
+		{
+			path:     "gs://bucket/cat/1.png",
+			name:     "cat/1.png",
+			labels:   []string{"cat"},
+			split:    splitValidation,
+			metadata: imageMetadata{width: 100, height: 100},
+			boxes: []boundingBox{
+				{label: "cat", xMin: 0, yMin: 0, xMax: 0.5, yMax: 0.5},
+			},
+		},
+		{
+			path:     "gs://bucket/cat_1.jpg",
+			name:     "cat_1.jpg",
+			split:    splitUnassigned,
+			metadata: imageMetadata{width: 100, height: 100},
+		},
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "list.csv")
+	records := []labelRecord{
+		{path: "gs://bucket/cat/1.jpg", labels: []string{"cat"}, split: splitTrain},
+		{path: "gs://bucket/dog/2.jpg", labels: []string{"dog", "puppy"}, split: splitTest},
+		{path: "gs://bucket/3.jpg", split: splitUnassigned},
+	}
+	if err := writeRecords(newLineWriter(&csvFormatter{}), output, records, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"list.csv", "gs://bucket/cat/1.jpg,cat\ngs://bucket/dog/2.jpg,dog,puppy\ngs://bucket/3.jpg,"},
+		{"train.csv", "gs://bucket/cat/1.jpg,cat"},
+		{"val.csv", ""},
+		{"test.csv", "gs://bucket/dog/2.jpg,dog,puppy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			result := readTestFile(t, filepath.Join(dir, tt.file))
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+	if files := listTestDir(t, dir); len(files) != len(tests) {
+		t.Errorf("expected only output files, but got %v", files)
+	}
+}
+
+func TestCOCOWriter(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "coco.json")
+	if err := writeRecords(&cocoWriter{}, output, newTestDetectionRecords(), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var data struct {
+		Images      []cocoImage      `json:"images"`
+		Annotations []cocoAnnotation `json:"annotations"`
+		Categories  []cocoCategory   `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(readTestFile(t, output)), &data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedImages := []cocoImage{
+		{ID: 1, FileName: "gs://bucket/cat/1.jpg", Width: 200, Height: 100},
+		{ID: 2, FileName: "gs://bucket/cat/1.png", Width: 100, Height: 100},
+		{ID: 3, FileName: "gs://bucket/cat_1.jpg", Width: 100, Height: 100},
+	}
+	if !reflect.DeepEqual(data.Images, expectedImages) {
+		t.Errorf("expected %v, but got %v", expectedImages, data.Images)
+	}
+	expectedAnnotations := []cocoAnnotation{
+		{ID: 1, ImageID: 1, CategoryID: 1, BBox: []float64{20, 20, 80, 40}, Area: 3200},
+		{ID: 2, ImageID: 1, CategoryID: 2, BBox: []float64{0, 0, 200, 100}, Area: 20000, Segmentation: [][]float64{{0, 0, 200, 0, 200, 100}}},
+		{ID: 3, ImageID: 2, CategoryID: 1, BBox: []float64{0, 0, 50, 50}, Area: 2500},
+	}
+	if !reflect.DeepEqual(data.Annotations, expectedAnnotations) {
+		t.Errorf("expected %v, but got %v", expectedAnnotations, data.Annotations)
+	}
+	expectedCategories := []cocoCategory{{ID: 1, Name: "cat"}, {ID: 2, Name: "dog"}}
+	if !reflect.DeepEqual(data.Categories, expectedCategories) {
+		t.Errorf("expected %v, but got %v", expectedCategories, data.Categories)
+	}
+
+	// empty split file is valid COCO JSON.
+	if err := json.Unmarshal([]byte(readTestFile(t, filepath.Join(dir, "test.json"))), &data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(data.Images) != 0 || len(data.Annotations) != 0 || len(data.Categories) != 0 {
+		t.Errorf("expected empty dataset, but got %v", data)
+	}
+
+	// temporary files of annotations must be removed.
+	if files := listTestDir(t, dir); len(files) != 4 {
+		t.Errorf("expected only output files, but got %v", files)
+	}
+}
+
+func TestCOCOWriterInvalidNumber(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "coco.json")
+	records := []labelRecord{
+		{
+			path:     "gs://bucket/cat/1.jpg",
+			split:    splitTrain,
+			metadata: imageMetadata{width: 100, height: 100},
+			boxes:    []boundingBox{{label: "cat", xMin: math.NaN(), yMin: 0, xMax: math.Inf(1), yMax: 1}},
+		},
+	}
+	if err := writeRecords(&cocoWriter{}, output, records, true); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// invalid JSON is not committed, and temporary files are removed.
+	if files := listTestDir(t, dir); len(files) != 0 {
+		t.Errorf("expected no output files, but got %v", files)
+	}
+}
+
+func TestVOCWriter(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "all.txt")
+	if err := writeRecords(newVOCWriter(), output, newTestDetectionRecords(), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// image ids are not collided for the same name without extension.
+	pngID := "cat_1_" + getURLHash("cat/1.png")[:8]
+	jpgID := "cat_1_" + getURLHash("cat_1.jpg")[:8]
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"all.txt", strings.Join([]string{"cat_1", pngID, jpgID}, "\n")},
+		{"train.txt", "cat_1"},
+		{"val.txt", pngID},
+		{"test.txt", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			result := readTestFile(t, filepath.Join(dir, tt.file))
+			if result != tt.expected {
+				t.Errorf("expected [%s], but got [%s]", tt.expected, result)
+			}
+		})
+	}
+
+	files := listTestDir(t, filepath.Join(dir, "Annotations"))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 annotation files, but got %v", files)
+	}
+
+	var a vocAnnotation
+	if err := xml.Unmarshal([]byte(readTestFile(t, filepath.Join(dir, "Annotations", "cat_1.xml"))), &a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Folder != "cat" || a.Filename != "1.jpg" || a.Size.Width != 200 || a.Size.Height != 100 {
+		t.Errorf("unexpected annotation: %v", a)
+	}
+	expected := []vocObject{
+		{Name: "cat", Pose: "Unspecified", BndBox: vocBndBox{XMin: 20, YMin: 20, XMax: 100, YMax: 60}},
+		{Name: "dog", Pose: "Unspecified", BndBox: vocBndBox{XMin: 0, YMin: 0, XMax: 200, YMax: 100}},
+	}
+	if !reflect.DeepEqual(a.Objects, expected) {
+		t.Errorf("expected %v, but got %v", expected, a.Objects)
+	}
+}
+
+func TestVOCWriterImageID(t *testing.T) {
+	w := newVOCWriter()
+	tests := []struct {
+		name     string
+		expected string
+		isNew    bool
+	}{
+		{"cat/1.jpg", "cat_1", true},
+		{"cat/1.jpg", "cat_1", false},
+		{"cat/1.png", "cat_1_" + getURLHash("cat/1.png")[:8], true},
+		{"dog/2.jpg", "dog_2", true},
+	}
+	for _, tt := range tests {
+		id, isNew, err := w.getImageID(tt.name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if id != tt.expected || isNew != tt.isNew {
+			t.Errorf("name=[%s]: expected [%s, %t], but got [%s, %t]", tt.name, tt.expected, tt.isNew, id, isNew)
+		}
+	}
+
+	// the id with hash suffix is also used by the other name.
+	w.ids["dog_3_"+getURLHash("dog/3.png")[:8]] = "other"
+	w.ids["dog_3"] = "dog/3.jpg"
+	if id, _, err := w.getImageID("dog/3.png"); err == nil {
+		t.Errorf("expected duplicate error, but got [%s]", id)
+	}
+}
+
+type pathFormatter struct{}
+
+func (pathFormatter) format(rec labelRecord) ([]string, error) {
+	return []string{rec.path}, nil
+}
+
+type errorStreamWriter struct {
+	recordWriter
+}
+
+func (w errorStreamWriter) begin(file string) (recordStream, error) {
+	s, err := w.recordWriter.begin(file)
+	if err != nil {
+		return nil, err
+	}
+	return errorStream{s}, nil
+}
+
+type errorStream struct {
+	recordStream
+}
+
+func (errorStream) write(rec labelRecord) error {
+	return errors.New("write error")
+}
+
+func TestWriteRecordsError(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "list.csv")
+	w := errorStreamWriter{newLineWriter(pathFormatter{})}
+	if err := writeRecords(w, output, []labelRecord{{path: "1.jpg", split: splitTrain}}, true); err == nil {
+		t.Errorf("expected error")
+	}
+
+	// no output and temporary files are left.
+	if files := listTestDir(t, dir); len(files) != 0 {
+		t.Errorf("expected no files, but got %v", files)
+	}
+}